@@ -0,0 +1,54 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vavuthu/itr/cmd/cleanup"
+	"github.com/vavuthu/itr/logger"
+)
+
+// cleanupCmd represents the cleanup command
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Stop and remove test containers left over by ITR runs",
+	Long:  `Stop and remove test containers left over by ITR runs. Without --run-id containers of all ITR runs are removed.`,
+	Run:   cleanupRunCmd,
+}
+
+var (
+	cleanupDryRun				bool
+	cleanupRunID				string
+)
+
+func init() {
+	cleanupCmd.Flags().StringVar(&cleanupRunID, "run-id", "", "run ID whose containers are removed, all ITR runs if not given")
+	cleanupCmd.Flags().BoolVar(&cleanupDryRun, "dry-run", false, "only list the containers that would be removed")
+	rootCmd.AddCommand(cleanupCmd)
+}
+
+func cleanupRunCmd(cmd *cobra.Command, args []string) {
+	containers, err := cleanup.Cleanup(cleanupRunID, cleanupDryRun)
+	if err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+	logger.Infof("%d ITR container(s) found", len(containers))
+}
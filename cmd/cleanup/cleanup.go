@@ -0,0 +1,112 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package cleanup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+
+	"github.com/vavuthu/itr/cmd/payload"
+	"github.com/vavuthu/itr/logger"
+)
+
+// Container is an ITR test container as listed by podman ps
+type Container struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	State  string            `json:"State"`
+	Labels map[string]string `json:"Labels"`
+}
+
+func (c Container) RunID() string {
+	return c.Labels[payload.LabelRunID]
+}
+
+func (c Container) TestID() string {
+	return c.Labels[payload.LabelTestID]
+}
+
+// ListContainers lists the ITR containers of the given run, all ITR
+// containers if runID is empty
+func ListContainers(runID string) ([]Container, error) {
+	filter := "label=" + payload.LabelRunID
+	if runID != "" {
+		filter += "=" + runID
+	}
+
+	output, err := exec.Command("podman", "ps", "--all", "--filter", filter, "--format", "json").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %v", err)
+	}
+
+	var containers []Container
+	if err := json.Unmarshal(output, &containers); err != nil {
+		return nil, fmt.Errorf("failed to parse podman ps output: %v", err)
+	}
+	return containers, nil
+}
+
+// Cleanup stops and removes the ITR containers of the given run, all ITR
+// containers if runID is empty. It returns the containers found.
+func Cleanup(runID string, dryRun bool) ([]Container, error) {
+	containers, err := ListContainers(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range containers {
+		logger.Infof("Found container %s (%s) of run %s for test case %s", c.Names, c.State, c.RunID(), c.TestID())
+		if dryRun {
+			continue
+		}
+		output, err := exec.Command("podman", "rm", "--force", c.ID).CombinedOutput()
+		if err != nil {
+			return containers, fmt.Errorf("failed to remove container %s: %v: %s", c.ID, err, output)
+		}
+		logger.Infof("Removed container %s", c.Names)
+	}
+	return containers, nil
+}
+
+// WarnOrphans warns about running ITR containers which don't belong to the
+// current run, they are most likely left over by a crashed run and still use the cluster
+func WarnOrphans(currentRunID string) {
+	containers, err := ListContainers("")
+	if err != nil {
+		logger.Debugf("Skipping orphaned container check: %v", err)
+		return
+	}
+
+	orphans := make(map[string]int)
+	for _, c := range containers {
+		if c.State == "running" && c.RunID() != currentRunID {
+			orphans[c.RunID()]++
+		}
+	}
+
+	runIDs := make([]string, 0, len(orphans))
+	for runID := range orphans {
+		runIDs = append(runIDs, runID)
+	}
+	sort.Strings(runIDs)
+	for _, runID := range runIDs {
+		logger.Warnf("%d container(s) of earlier ITR run %s are still running, remove them with: itr cleanup --run-id %s", orphans[runID], runID, runID)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vavuthu/itr/cmd/compare"
+	"github.com/vavuthu/itr/cmd/history"
+	"github.com/vavuthu/itr/cmd/report"
+	"github.com/vavuthu/itr/cmd/results"
+	"github.com/vavuthu/itr/cmd/rundir"
+	"github.com/vavuthu/itr/config"
+	"github.com/vavuthu/itr/logger"
+)
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare <base> <head>",
+	Short: "Compare two runs",
+	Long: `Compare two runs, each given as a run directory or as a run ID from the history. Shows the test cases whose
+status changed, the added and removed test cases, the duration regressions and the differences of the image,
+host and environment table of the test framework report.`,
+	Args: cobra.ExactArgs(2),
+	Run:  compareRunCmd,
+}
+
+var (
+	compareDurationRatio			float64
+	compareFormats				[]string
+	compareHistoryDB			string
+	compareMinDurationIncrease		time.Duration
+)
+
+func init() {
+	compareCmd.Flags().StringArrayVar(&compareFormats, "format", nil, "output in FORMAT[=PATH] form, formats are console, html and json, stdout if no path is given (default console)")
+	compareCmd.Flags().StringVar(&compareHistoryDB, "history-db", "", "history database run IDs are looked up in (default history.db in the default output dir)")
+	compareCmd.Flags().Float64Var(&compareDurationRatio, "duration-ratio", 1.5, "how many times slower a test case has to be to be reported as a duration regression")
+	compareCmd.Flags().DurationVar(&compareMinDurationIncrease, "min-duration-increase", 30*time.Second, "how much slower a test case has to be to be reported as a duration regression")
+	rootCmd.AddCommand(compareCmd)
+}
+
+func compareRunCmd(cmd *cobra.Command, args []string) {
+	formats, err := config.ParseReports(compareFormats)
+	if err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+	if len(formats) == 0 {
+		formats = []config.Report{{Format: compare.Console}}
+	}
+	for _, format := range formats {
+		if err := compare.ValidateFormat(format.Format); err != nil {
+			logger.Errorf("An error occurred: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	base, err := loadRun(args[0])
+	if err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+	head, err := loadRun(args[1])
+	if err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+	comparison := compare.Compare(base, head, compare.Options{
+		DurationRatio:       compareDurationRatio,
+		MinDurationIncrease: compareMinDurationIncrease,
+	})
+	if err := writeComparison(comparison, formats); err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+}
+
+// loadRun returns the results of a run directory, or of a run ID from the history
+func loadRun(run string) (results.Document, error) {
+	if info, err := os.Stat(run); err == nil && info.IsDir() {
+		runDir := filepath.Clean(run)
+		config.AppConfig.OutputDir = filepath.Dir(runDir)
+		config.AppConfig.RunID = filepath.Base(runDir)
+		r, err := report.Load()
+		if err != nil {
+			return results.Document{}, err
+		}
+		return r.Document, nil
+	}
+
+	path := history.Path(compareHistoryDB, rundir.DefaultOutputDir)
+	if _, err := os.Stat(path); err != nil {
+		return results.Document{}, fmt.Errorf("%s is neither a run directory nor a run in the history: %v", run, err)
+	}
+	store, err := history.Open(path)
+	if err != nil {
+		return results.Document{}, err
+	}
+	defer store.Close()
+	doc, err := store.Get(run)
+	if err == nil && doc == nil {
+		err = fmt.Errorf("%s is neither a run directory nor a run in the history %s", run, path)
+	}
+	if err != nil {
+		return results.Document{}, err
+	}
+	return *doc, nil
+}
+
+// writeComparison writes the comparison in the formats to their paths or stdout
+func writeComparison(comparison compare.Comparison, formats []config.Report) error {
+	for _, format := range formats {
+		if format.Path == "" || format.Path == report.Stdout {
+			if err := compare.Write(os.Stdout, comparison, format.Format); err != nil {
+				return err
+			}
+			continue
+		}
+		file, err := os.Create(format.Path)
+		if err != nil {
+			return err
+		}
+		err = compare.Write(file, comparison, format.Format)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		logger.Infof("%s comparison '%s' generated successfully.", format.Format, format.Path)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package compare
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vavuthu/itr/cmd/results"
+	"github.com/vavuthu/itr/cmd/runstate"
+)
+
+// Options are the thresholds of the comparison
+type Options struct {
+	// DurationRatio is how many times slower a test case has to be to count
+	// as a duration regression
+	DurationRatio float64
+	// MinDurationIncrease ignores regressions of short test cases
+	MinDurationIncrease time.Duration
+}
+
+// Comparison is the difference between a base run and a head run
+type Comparison struct {
+	Base                Run              `json:"base"`
+	Head                Run              `json:"head"`
+	StatusChanges       []StatusChange   `json:"statusChanges"`
+	Added               []TestStatus     `json:"added"`
+	Removed             []TestStatus     `json:"removed"`
+	DurationRegressions []DurationChange `json:"durationRegressions"`
+	Environment         []EnvChange      `json:"environment"`
+}
+
+// Run describes a compared run
+type Run struct {
+	ID        string                  `json:"id"`
+	Suite     string                  `json:"suite"`
+	StartTime time.Time               `json:"startTime"`
+	Total     int                     `json:"total"`
+	Statuses  map[runstate.Status]int `json:"statuses"`
+}
+
+// StatusChange is a test case whose final status changed
+type StatusChange struct {
+	ID   string          `json:"id"`
+	From runstate.Status `json:"from"`
+	To   runstate.Status `json:"to"`
+}
+
+// TestStatus is a test case only one of the runs has
+type TestStatus struct {
+	ID     string          `json:"id"`
+	Status runstate.Status `json:"status"`
+}
+
+// DurationChange is a test case that got slower
+type DurationChange struct {
+	ID          string  `json:"id"`
+	BaseSeconds float64 `json:"baseSeconds"`
+	HeadSeconds float64 `json:"headSeconds"`
+	Ratio       float64 `json:"ratio"`
+}
+
+// EnvChange is a differing value of the run metadata or the environment
+// table of the test framework report, empty if a run doesn't have it
+type EnvChange struct {
+	Key  string `json:"key"`
+	Base string `json:"base"`
+	Head string `json:"head"`
+}
+
+// Compare returns the differences of head to base
+func Compare(base, head results.Document, opts Options) Comparison {
+	c := Comparison{
+		Base:                newRun(base),
+		Head:                newRun(head),
+		StatusChanges:       []StatusChange{},
+		Added:               []TestStatus{},
+		Removed:             []TestStatus{},
+		DurationRegressions: []DurationChange{},
+		Environment:         []EnvChange{},
+	}
+
+	baseTests := make(map[string]results.Test, len(base.Tests))
+	for _, test := range base.Tests {
+		baseTests[test.ID] = test
+	}
+	headTests := make(map[string]bool, len(head.Tests))
+	for _, test := range head.Tests {
+		headTests[test.ID] = true
+		old, ok := baseTests[test.ID]
+		if !ok {
+			c.Added = append(c.Added, TestStatus{ID: test.ID, Status: test.Status})
+			continue
+		}
+		if old.Status != test.Status {
+			c.StatusChanges = append(c.StatusChanges, StatusChange{ID: test.ID, From: old.Status, To: test.Status})
+		}
+		if change, ok := durationRegression(old, test, opts); ok {
+			c.DurationRegressions = append(c.DurationRegressions, change)
+		}
+	}
+	for _, test := range base.Tests {
+		if !headTests[test.ID] {
+			c.Removed = append(c.Removed, TestStatus{ID: test.ID, Status: test.Status})
+		}
+	}
+	sort.Slice(c.DurationRegressions, func(i, j int) bool {
+		a, b := c.DurationRegressions[i], c.DurationRegressions[j]
+		return a.HeadSeconds-a.BaseSeconds > b.HeadSeconds-b.BaseSeconds
+	})
+
+	c.Environment = envChanges(base.Run, head.Run)
+	return c
+}
+
+func newRun(doc results.Document) Run {
+	return Run{
+		ID:        doc.Run.ID,
+		Suite:     doc.Run.Suite,
+		StartTime: doc.Run.StartTime,
+		Total:     doc.Summary.Total,
+		Statuses:  doc.Summary.Statuses,
+	}
+}
+
+// durationRegression reports whether the test case got slower by the ratio
+// and the minimum increase of the options
+func durationRegression(base, head results.Test, opts Options) (DurationChange, bool) {
+	if base.Status == runstate.NotSelected || head.Status == runstate.NotSelected {
+		return DurationChange{}, false
+	}
+	baseSeconds, headSeconds := base.FinalDurationSeconds(), head.FinalDurationSeconds()
+	if baseSeconds <= 0 || headSeconds < baseSeconds*opts.DurationRatio ||
+		headSeconds-baseSeconds < opts.MinDurationIncrease.Seconds() {
+		return DurationChange{}, false
+	}
+	return DurationChange{ID: head.ID, BaseSeconds: baseSeconds, HeadSeconds: headSeconds, Ratio: headSeconds / baseSeconds}, true
+}
+
+// envChanges returns the differing image, host and environment table values
+func envChanges(base, head results.Run) []EnvChange {
+	changes := []EnvChange{}
+	for _, field := range []EnvChange{
+		{Key: "Image", Base: base.Image, Head: head.Image},
+		{Key: "Host", Base: base.Host, Head: head.Host},
+	} {
+		if field.Base != field.Head {
+			changes = append(changes, field)
+		}
+	}
+
+	keys := make(map[string]bool)
+	for key := range base.Environment {
+		keys[key] = true
+	}
+	for key := range head.Environment {
+		keys[key] = true
+	}
+	var env []EnvChange
+	for key := range keys {
+		if base.Environment[key] != head.Environment[key] {
+			env = append(env, EnvChange{Key: key, Base: base.Environment[key], Head: head.Environment[key]})
+		}
+	}
+	sort.Slice(env, func(i, j int) bool { return env[i].Key < env[j].Key })
+	return append(changes, env...)
+}
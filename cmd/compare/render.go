@@ -0,0 +1,161 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package compare
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+
+	"github.com/vavuthu/itr/cmd/runstate"
+)
+
+// Formats of the comparison
+const (
+	Console = "console"
+	HTML    = "html"
+	JSON    = "json"
+)
+
+// ValidateFormat checks the comparison can be written in the format
+func ValidateFormat(format string) error {
+	switch format {
+	case Console, HTML, JSON:
+		return nil
+	}
+	return fmt.Errorf("unknown comparison format %q, expected %s, %s or %s", format, Console, HTML, JSON)
+}
+
+// Write writes the comparison in a format
+func Write(w io.Writer, c Comparison, format string) error {
+	switch format {
+	case Console:
+		return writeConsole(w, c)
+	case HTML:
+		return writeHTML(w, c)
+	case JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(c)
+	}
+	return ValidateFormat(format)
+}
+
+// statuses returns the statuses of both runs in a stable order
+func statuses(c Comparison) []runstate.Status {
+	seen := make(map[runstate.Status]bool)
+	var list []runstate.Status
+	for _, counts := range []map[runstate.Status]int{c.Base.Statuses, c.Head.Statuses} {
+		for status := range counts {
+			if !seen[status] {
+				seen[status] = true
+				list = append(list, status)
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+func writeConsole(w io.Writer, c Comparison) error {
+	summary := []table.Row{{"suite", c.Base.Suite, c.Head.Suite}, {"total", c.Base.Total, c.Head.Total}}
+	for _, status := range statuses(c) {
+		summary = append(summary, table.Row{status, c.Base.Statuses[status], c.Head.Statuses[status]})
+	}
+	var statusChanges, added, removed, durations, environment []table.Row
+	for _, change := range c.StatusChanges {
+		statusChanges = append(statusChanges, table.Row{change.ID, change.From, change.To})
+	}
+	for _, test := range c.Added {
+		added = append(added, table.Row{test.ID, test.Status})
+	}
+	for _, test := range c.Removed {
+		removed = append(removed, table.Row{test.ID, test.Status})
+	}
+	for _, change := range c.DurationRegressions {
+		durations = append(durations, table.Row{change.ID, seconds(change.BaseSeconds), seconds(change.HeadSeconds), fmt.Sprintf("%.1fx", change.Ratio)})
+	}
+	for _, change := range c.Environment {
+		environment = append(environment, table.Row{change.Key, change.Base, change.Head})
+	}
+
+	sections := []struct {
+		title  string
+		header table.Row
+		rows   []table.Row
+	}{
+		{"Summary", table.Row{"", "Base " + c.Base.ID, "Head " + c.Head.ID}, summary},
+		{fmt.Sprintf("Status changes (%d)", len(statusChanges)), table.Row{"Test Case", "Base", "Head"}, statusChanges},
+		{fmt.Sprintf("Added test cases (%d)", len(added)), table.Row{"Test Case", "Status"}, added},
+		{fmt.Sprintf("Removed test cases (%d)", len(removed)), table.Row{"Test Case", "Status"}, removed},
+		{fmt.Sprintf("Duration regressions (%d)", len(durations)), table.Row{"Test Case", "Base", "Head", "Ratio"}, durations},
+		{fmt.Sprintf("Environment differences (%d)", len(environment)), table.Row{"Key", "Base", "Head"}, environment},
+	}
+
+	for _, section := range sections {
+		if _, err := fmt.Fprintln(w, section.title); err != nil {
+			return err
+		}
+		if len(section.rows) == 0 {
+			continue
+		}
+		t := table.NewWriter()
+		t.SetOutputMirror(w)
+		t.AppendHeader(section.header)
+		t.AppendRows(section.rows)
+		t.SetColumnConfigs([]table.ColumnConfig{{Number: 1, WidthMax: 120}})
+		t.Render()
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// seconds formats a duration in seconds for the comparison
+func seconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(100 * time.Millisecond).String()
+}
+
+// htmlTemplate is a self-contained page like the HTML report
+//
+//go:embed compare.html
+var htmlTemplate string
+
+var compareTemplate = template.Must(template.New("compare").Funcs(template.FuncMap{"seconds": seconds}).Parse(htmlTemplate))
+
+type htmlCount struct {
+	Status runstate.Status
+	Base   int
+	Head   int
+}
+
+func writeHTML(w io.Writer, c Comparison) error {
+	var counts []htmlCount
+	for _, status := range statuses(c) {
+		counts = append(counts, htmlCount{Status: status, Base: c.Base.Statuses[status], Head: c.Head.Statuses[status]})
+	}
+	return compareTemplate.Execute(w, struct {
+		Comparison
+		Counts []htmlCount
+	}{c, counts})
+}
@@ -20,44 +20,95 @@ package engine
 import (
 	"github.com/vavuthu/itr/cmd/launcher"
 	"github.com/vavuthu/itr/cmd/payload"
-	"github.com/vavuthu/itr/cmd/statusquo"
-	"github.com/vavuthu/itr/cmd/utils"
+	"github.com/vavuthu/itr/cmd/runstate"
 	"github.com/vavuthu/itr/config"
 	"github.com/vavuthu/itr/logger"
 )
 
+// Names of the queues the test cases run in
+const (
+	ParallelQueue = "parallel"
+	SerialQueue = "serial"
+	// IsolationQueue reruns the parallel test cases that failed for good one at a time
+	IsolationQueue = "isolation"
+)
+
 func RunEngine(execution, configDir, nonDisruptiveTestCases, disruptiveTestCases, image string, queueLength, retry int, junitXML bool) {
 	logger.Info("Starting ITR engine")
-	
-	filenames := []string{nonDisruptiveTestCases, disruptiveTestCases}
-	totalCount, err := utils.CountLines(filenames)
-	if err != nil {
-			logger.Error("error in reading file %s ", err)
-			return
+	runstate.Current.Start(config.AppConfig.RunID)
+
+	// all the test cases are queued up front so the run state knows the total
+	var parallelCommands, serialCommands []payload.PodmanCommand
+	if len(nonDisruptiveTestCases) != 0 {
+		parallelCommands = generateCommands(execution, configDir, nonDisruptiveTestCases, image, junitXML)
+		queue(parallelCommands, ParallelQueue)
+	}
+	if len(disruptiveTestCases) != 0 {
+		serialCommands = generateCommands(execution, configDir, disruptiveTestCases, image, junitXML)
+		queue(serialCommands, SerialQueue)
 	}
-	statusquo.TotalTestCases = totalCount
 
 	if len(nonDisruptiveTestCases) != 0 {
-		RunEngineParallely(execution, configDir, nonDisruptiveTestCases, image, queueLength, retry, junitXML)
+		RunEngineParallely(parallelCommands, configDir, queueLength, retry)
+	}
+
+	if config.AppConfig.IsolateFailures && len(nonDisruptiveTestCases) != 0 {
+		if len(disruptiveTestCases) == 0 {
+			config.UpdateConfigEnv("isSerialEngineNeeded", false)
+		}
+		RunEngineIsolated(failedForGood(parallelCommands))
 	}
 
 	if len(disruptiveTestCases) != 0 {
 		// set parameter isSerialEngineNeeded to false, so that launcher will invoke to generate
 		// report and sends email
 		config.UpdateConfigEnv("isSerialEngineNeeded", false)
-		RunEngineSerially(execution, configDir, disruptiveTestCases, image, queueLength, retry, junitXML)
+		RunEngineSerially(serialCommands, configDir, retry)
 	}
 }
 
-func RunEngineParallely(execution, configDir, nonDisruptiveTestCases, image string, queueLength, retry int, junitXML bool) {
+func RunEngineParallely(commands []payload.PodmanCommand, configDir string, queueLength, retry int) {
 	logger.Info("Running engine parallely")
-	commands := payload.GenerateAllPodmanCommands(execution, configDir, nonDisruptiveTestCases, image, junitXML)
-	launcher.LaunchInitiate(commands, configDir, queueLength, retry)
+	launcher.LaunchInitiate(commands, configDir, ParallelQueue, queueLength, retry)
 }
 
-func RunEngineSerially(execution, configDir, disruptiveTestCases, image string, queueLength, retry int, junitXML bool) {
+func RunEngineSerially(commands []payload.PodmanCommand, configDir string, retry int) {
 	logger.Info("Running engine serially")
-	commands := payload.GenerateAllPodmanCommands(execution, configDir, disruptiveTestCases, image, junitXML)
-	queueLength = 1
-	launcher.LaunchInitiate(commands, configDir, queueLength, retry)
+	queueLength := 1
+	launcher.LaunchInitiate(commands, configDir, SerialQueue, queueLength, retry)
+}
+
+// RunEngineIsolated reruns the commands one at a time to tell the failures
+// caused by running in parallel from the ones of the test cases themselves
+func RunEngineIsolated(commands []payload.PodmanCommand) {
+	logger.Infof("Rerunning %d failed test cases in isolation", len(commands))
+	launcher.LaunchIsolated(commands, IsolationQueue)
+}
+
+// failedForGood returns the commands of the test cases that failed after all
+// their attempts
+func failedForGood(commands []payload.PodmanCommand) []payload.PodmanCommand {
+	var failed []payload.PodmanCommand
+	for _, cmd := range commands {
+		test, ok := runstate.Current.Test(cmd.TestCase)
+		if !ok {
+			continue
+		}
+		if test.FinalStatus().Failing() {
+			failed = append(failed, cmd)
+		}
+	}
+	return failed
+}
+
+// generateCommands returns the podman commands of the test cases in file
+func generateCommands(execution, configDir, file, image string, junitXML bool) []payload.PodmanCommand {
+	return payload.GenerateAllPodmanCommands(execution, configDir, file, image, junitXML, config.AppConfig.Container, config.AppConfig.ContainerEnv, config.AppConfig.Manifest)
+}
+
+// queue adds the test cases of the commands to the run state
+func queue(commands []payload.PodmanCommand, queue string) {
+	for _, cmd := range commands {
+		runstate.Current.Queue(cmd.TestCase, queue)
+	}
 }
@@ -0,0 +1,82 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package history
+
+import (
+	"sort"
+
+	"github.com/vavuthu/itr/cmd/results"
+	"github.com/vavuthu/itr/cmd/runstate"
+)
+
+// Changes are the differences of a run to the previous run of its suite
+type Changes struct {
+	PreviousRunID string
+	// NewFailures failed in this run but not in the previous one
+	NewFailures []string
+	// Fixed failed in the previous run and passed in this one
+	Fixed []string
+	// StillFailing failed in both runs
+	StillFailing []string
+	// NewlyFlaky are flaky in this run but weren't in the previous one
+	NewlyFlaky []string
+	// FlakyRate is the share of flaky test cases of the run
+	FlakyRate         float64
+	PreviousFlakyRate float64
+}
+
+// Compare returns the changes of the current run to the previous one
+func Compare(previous, current results.Document) Changes {
+	changes := Changes{
+		PreviousRunID:     previous.Run.ID,
+		FlakyRate:         FlakyRate(current),
+		PreviousFlakyRate: FlakyRate(previous),
+	}
+	before := make(map[string]results.Test, len(previous.Tests))
+	for _, test := range previous.Tests {
+		before[test.ID] = test
+	}
+
+	for _, test := range current.Tests {
+		old, ok := before[test.ID]
+		switch {
+		case test.Status.Failing() && ok && old.Status.Failing():
+			changes.StillFailing = append(changes.StillFailing, test.ID)
+		case test.Status.Failing():
+			changes.NewFailures = append(changes.NewFailures, test.ID)
+		case test.Status.Passing() && ok && old.Status.Failing():
+			changes.Fixed = append(changes.Fixed, test.ID)
+		}
+		if test.Flaky && !(ok && old.Flaky) {
+			changes.NewlyFlaky = append(changes.NewlyFlaky, test.ID)
+		}
+	}
+	for _, ids := range [][]string{changes.NewFailures, changes.Fixed, changes.StillFailing, changes.NewlyFlaky} {
+		sort.Strings(ids)
+	}
+	return changes
+}
+
+// FlakyRate returns the share of flaky test cases among the test cases that ran
+func FlakyRate(doc results.Document) float64 {
+	ran := doc.Summary.Total - doc.Summary.Statuses[runstate.NotSelected]
+	if ran <= 0 {
+		return 0
+	}
+	return float64(doc.Summary.Flaky) / float64(ran)
+}
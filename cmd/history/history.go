@@ -0,0 +1,167 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/vavuthu/itr/cmd/results"
+)
+
+// FileName is the name of the history database in the output directory
+const FileName = "history.db"
+
+// Disabled as history path turns the history off
+const Disabled = "none"
+
+var (
+	// runsBucket maps the run IDs to their results documents
+	runsBucket = []byte("runs")
+	// suitesBucket has a bucket per suite mapping the start time and run ID
+	// of its runs to the run ID, so they are ordered by start time
+	suitesBucket = []byte("suites")
+)
+
+// lockTimeout is how long to wait for another ITR run using the database
+const lockTimeout = 30 * time.Second
+
+// Store records the results of every run, it is shared by the runs in an
+// output directory
+type Store struct {
+	db *bolt.DB
+}
+
+// Path returns the path of the history database given with --history-db,
+// the one in outputDir by default
+func Path(path, outputDir string) string {
+	if path == "" {
+		return filepath.Join(outputDir, FileName)
+	}
+	return path
+}
+
+// Open opens the history database at path, creating it if needed
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{runsBucket, suitesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize history %s: %v", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record stores the results of a run, replacing an earlier record of it
+func (s *Store) Record(doc results.Document) error {
+	content, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(runsBucket).Put([]byte(doc.Run.ID), content); err != nil {
+			return err
+		}
+		suite, err := tx.Bucket(suitesBucket).CreateBucketIfNotExists(suiteName(doc.Run))
+		if err != nil {
+			return err
+		}
+		return suite.Put(suiteKey(doc.Run), []byte(doc.Run.ID))
+	})
+}
+
+// Get returns the results of a run, nil if the run isn't recorded
+func (s *Store) Get(runID string) (*results.Document, error) {
+	var doc *results.Document
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		doc, err = get(tx, runID)
+		return err
+	})
+	return doc, err
+}
+
+// Previous returns the results of the last run of the suite started before
+// the run, nil if there is none
+func (s *Store) Previous(run results.Run) (*results.Document, error) {
+	var doc *results.Document
+	err := s.db.View(func(tx *bolt.Tx) error {
+		suite := tx.Bucket(suitesBucket).Bucket(suiteName(run))
+		if suite == nil {
+			return nil
+		}
+		cursor := suite.Cursor()
+		key, _ := cursor.Seek(suiteKey(run))
+		if key == nil {
+			key, _ = cursor.Last()
+		} else {
+			key, _ = cursor.Prev()
+		}
+		if key == nil {
+			return nil
+		}
+		var err error
+		doc, err = get(tx, string(suite.Get(key)))
+		return err
+	})
+	return doc, err
+}
+
+func get(tx *bolt.Tx, runID string) (*results.Document, error) {
+	content := tx.Bucket(runsBucket).Get([]byte(runID))
+	if content == nil {
+		return nil, nil
+	}
+	var doc results.Document
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("invalid history record of run %s: %v", runID, err)
+	}
+	return &doc, nil
+}
+
+// suiteName returns the bucket name of the suite of the run
+func suiteName(run results.Run) []byte {
+	if run.Suite == "" {
+		return []byte("default")
+	}
+	return []byte(run.Suite)
+}
+
+// suiteKey orders the runs of a suite by start time
+func suiteKey(run results.Run) []byte {
+	return []byte(run.StartTime.UTC().Format("20060102T150405.000000000") + "/" + run.ID)
+}
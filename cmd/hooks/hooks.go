@@ -0,0 +1,103 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/vavuthu/itr/cmd/runstate"
+	"github.com/vavuthu/itr/logger"
+)
+
+// timeout is the time a hook command may run before it is killed
+const timeout = 5 * time.Minute
+
+// DefaultHangHooks dump the container state when no hang hook is configured
+var DefaultHangHooks = []string{
+	`podman inspect "$ITR_CONTAINER"`,
+	`podman top "$ITR_CONTAINER"`,
+}
+
+// Context describes the test case attempt a hook runs for, it is passed to
+// the hook commands as ITR_* environment variables
+type Context struct {
+	Event       string
+	RunID       string
+	TestID      string
+	Attempt     int
+	Container   string
+	LogFile     string
+	ArtifactDir string
+}
+
+func (c Context) env() []string {
+	return []string{
+		"ITR_HOOK=" + c.Event,
+		"ITR_RUN_ID=" + c.RunID,
+		"ITR_TEST_ID=" + c.TestID,
+		"ITR_ATTEMPT=" + strconv.Itoa(c.Attempt),
+		"ITR_CONTAINER=" + c.Container,
+		"ITR_LOG_FILE=" + c.LogFile,
+		"ITR_ARTIFACTS_DIR=" + c.ArtifactDir,
+	}
+}
+
+// Run runs the commands one after the other with sh, their output is written
+// to hook-<event>.log in the artifact directory of the attempt
+func Run(commands []string, ctx Context) {
+	output, err := os.OpenFile(filepath.Join(ctx.ArtifactDir, "hook-"+ctx.Event+".log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		logger.Errorf("Failed to create output file of %s hooks: %v", ctx.Event, err)
+		return
+	}
+	defer output.Close()
+
+	for _, command := range commands {
+		logger.Infof("Running %s hook for test case %s (attempt %d): %s", ctx.Event, ctx.TestID, ctx.Attempt, command)
+		fmt.Fprintf(output, "$ %s\n", command)
+		start := time.Now()
+		err := run(command, ctx, output)
+		exitCode := 0
+		if err != nil {
+			exitCode = -1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				exitCode = exitErr.ExitCode()
+			}
+			logger.Warnf("%s hook %q failed for test case %s: %v", ctx.Event, command, ctx.TestID, err)
+			fmt.Fprintf(output, "hook failed: %v\n", err)
+		}
+		runstate.Current.HookExecuted(ctx.Event, ctx.TestID, ctx.Attempt, command, exitCode, time.Since(start))
+	}
+}
+
+func run(command string, ctx Context, output *os.File) error {
+	cmdCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(cmdCtx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), ctx.env()...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	return cmd.Run()
+}
@@ -0,0 +1,182 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/vavuthu/itr/cmd/rundir"
+	"github.com/vavuthu/itr/cmd/runstate"
+	"github.com/vavuthu/itr/logger"
+)
+
+// Version is the version of the event format, it is written with every event
+const Version = 1
+
+// Record is a line of the event log
+type Record struct {
+	Version int   `json:"v"`
+	Seq     int64 `json:"seq"`
+	runstate.Event
+}
+
+// Journal writes the events of the run to a file, one JSON object per line
+type Journal struct {
+	mu   sync.Mutex
+	file *os.File
+	seq  int64
+}
+
+// Start creates the event log at path and writes every event of state to it
+func Start(state *runstate.State, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create event log: %v", err)
+	}
+	j := &Journal{file: file}
+	state.Listen(j.write)
+	return nil
+}
+
+func (j *Journal) write(event runstate.Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.seq++
+	line, err := json.Marshal(Record{Version: Version, Seq: j.seq, Event: event})
+	if err != nil {
+		logger.Errorf("Failed to encode event %s: %v", event.Type, err)
+		return
+	}
+	// hook commands may contain secrets
+	if _, err := j.file.WriteString(logger.Mask(string(line)) + "\n"); err != nil {
+		logger.Errorf("Failed to write event log: %v", err)
+	}
+}
+
+// Read returns the events of the event log at path
+func Read(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return records, fmt.Errorf("invalid event %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// Tests replays the events into the test cases of the run, for runs that
+// ended before writing their results. The attempts get the log and artifact
+// paths of the current run directory.
+func Tests(records []Record) []runstate.Test {
+	tests := make(map[string]*runstate.Test)
+	var order []string
+	test := func(testID string) *runstate.Test {
+		t, ok := tests[testID]
+		if !ok {
+			t = &runstate.Test{ID: testID, Status: runstate.Queued}
+			tests[testID] = t
+			order = append(order, testID)
+		}
+		return t
+	}
+
+	for _, r := range records {
+		if r.TestID == "" {
+			continue
+		}
+		t := test(r.TestID)
+		switch r.Type {
+		case "test_queued":
+			t.Queue = r.Queue
+		case "attempt_started":
+			t.Status = runstate.Running
+			t.Attempts = append(t.Attempts, runstate.Attempt{
+				Number:      r.Attempt,
+				Status:      runstate.Running,
+				StartTime:   r.Time,
+				LogFile:     rundir.LogFile(r.TestID, r.Attempt),
+				ArtifactDir: rundir.ArtifactDir(r.TestID, r.Attempt),
+			})
+		case "attempt_finished":
+			n := len(t.Attempts)
+			if n == 0 || t.Attempts[n-1].Number != r.Attempt {
+				continue
+			}
+			attempt := &t.Attempts[n-1]
+			attempt.Status = r.Status
+			attempt.EndTime = r.Time
+			attempt.Duration = r.Duration
+			attempt.Result = r.Result
+			if r.ExitCode != nil {
+				attempt.ExitCode = *r.ExitCode
+			}
+			// like the launcher, a failed attempt keeps the test case running
+			// until it is retried or failed
+			if r.Status != runstate.Failed && r.Status != runstate.Hung {
+				t.Status = r.Status
+			}
+		case "isolation_started":
+			t.Isolation = &runstate.Attempt{
+				Number:      r.Attempt,
+				Status:      runstate.Running,
+				StartTime:   r.Time,
+				LogFile:     rundir.LogFile(r.TestID, r.Attempt),
+				ArtifactDir: rundir.ArtifactDir(r.TestID, r.Attempt),
+			}
+		case "isolation_finished":
+			if t.Isolation == nil || t.Isolation.Number != r.Attempt {
+				continue
+			}
+			t.Isolation.Status = r.Status
+			t.Isolation.EndTime = r.Time
+			t.Isolation.Duration = r.Duration
+			t.Isolation.Result = r.Result
+			if r.ExitCode != nil {
+				t.Isolation.ExitCode = *r.ExitCode
+			}
+		case "retry_scheduled":
+			t.Status = runstate.Retrying
+		case "test_failed":
+			t.Status = runstate.Failed
+		}
+	}
+
+	result := make([]runstate.Test, 0, len(order))
+	for _, testID := range order {
+		result = append(result, *tests[testID])
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+)
+
+// TestSuites is the root element of a JUnit XML file
+type TestSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr,omitempty"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     float64     `xml:"time,attr"`
+	Suites   []TestSuite `xml:"testsuite"`
+}
+
+// TestSuite is a testsuite element, pytest writes a single one per file
+type TestSuite struct {
+	XMLName   xml.Name   `xml:"testsuite"`
+	Name      string     `xml:"name,attr"`
+	Tests     int        `xml:"tests,attr"`
+	Failures  int        `xml:"failures,attr"`
+	Errors    int        `xml:"errors,attr"`
+	Skipped   int        `xml:"skipped,attr"`
+	Time      float64    `xml:"time,attr"`
+	Timestamp string     `xml:"timestamp,attr,omitempty"`
+	Hostname  string     `xml:"hostname,attr,omitempty"`
+	Cases     []TestCase `xml:"testcase"`
+}
+
+// TestCase is a testcase element. Reruns are written in the format of the
+// maven surefire plugin, which Jenkins understands.
+type TestCase struct {
+	ClassName     string  `xml:"classname,attr"`
+	Name          string  `xml:"name,attr"`
+	File          string  `xml:"file,attr,omitempty"`
+	Line          string  `xml:"line,attr,omitempty"`
+	Time          float64 `xml:"time,attr"`
+	Failure       *Result `xml:"failure"`
+	Error         *Result `xml:"error"`
+	Skipped       *Result `xml:"skipped"`
+	FlakyFailures []Rerun `xml:"flakyFailure"`
+	FlakyErrors   []Rerun `xml:"flakyError"`
+	RerunFailures []Rerun `xml:"rerunFailure"`
+	RerunErrors   []Rerun `xml:"rerunError"`
+	SystemOut     string  `xml:"system-out,omitempty"`
+	SystemErr     string  `xml:"system-err,omitempty"`
+}
+
+// Result is the failure, error or skipped element of a test case
+type Result struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// Rerun is an earlier failed attempt of a test case
+type Rerun struct {
+	Message    string `xml:"message,attr,omitempty"`
+	Type       string `xml:"type,attr,omitempty"`
+	StackTrace string `xml:"stackTrace,omitempty"`
+	SystemOut  string `xml:"system-out,omitempty"`
+	SystemErr  string `xml:"system-err,omitempty"`
+}
+
+// Parse reads a JUnit XML file with either a testsuites or a testsuite root
+func Parse(path string) ([]TestSuite, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var suites TestSuites
+	if err := xml.Unmarshal(content, &suites); err == nil {
+		return suites.Suites, nil
+	}
+	var suite TestSuite
+	if err := xml.Unmarshal(content, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse JUnit XML %s: %v", path, err)
+	}
+	return []TestSuite{suite}, nil
+}
+
+// Cases returns the test cases of all the suites
+func Cases(suites []TestSuite) []TestCase {
+	var cases []TestCase
+	for _, suite := range suites {
+		cases = append(cases, suite.Cases...)
+	}
+	return cases
+}
+
+// Failed reports whether the test case failed or errored
+func (c TestCase) Failed() bool {
+	return c.Failure != nil || c.Error != nil
+}
+
+// key identifies a test case across attempts
+func (c TestCase) key() string {
+	return c.ClassName + "::" + c.Name
+}
@@ -0,0 +1,235 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/vavuthu/itr/cmd/results"
+	"github.com/vavuthu/itr/cmd/rundir"
+	"github.com/vavuthu/itr/cmd/runstate"
+	"github.com/vavuthu/itr/cmd/utils"
+	"github.com/vavuthu/itr/config"
+	"github.com/vavuthu/itr/logger"
+)
+
+// maxOutput is the size of the log tail used as output of a test case
+const maxOutput = 64 * 1024
+
+// Merge returns a single JUnit document of the test cases of the run with a
+// testsuite per queue. The attempts of a test case are merged into its final
+// result: earlier failed attempts become flaky failures when the test case
+// passed at last and rerun failures when it didn't. Test cases that wrote no
+// JUnit XML are reported from their exit code and log. The paths of the
+// attempts are relative to runDir.
+func Merge(name string, tests []results.Test, runDir string) TestSuites {
+	doc := TestSuites{Name: name}
+	suites := make(map[string]*TestSuite)
+	var queues []string
+
+	for _, test := range tests {
+		if test.Status == runstate.NotSelected || len(test.Attempts) == 0 {
+			continue
+		}
+		suite, ok := suites[test.Queue]
+		if !ok {
+			suite = &TestSuite{Name: test.Queue}
+			suites[test.Queue] = suite
+			queues = append(queues, test.Queue)
+		}
+		suite.Cases = append(suite.Cases, mergeAttempts(test, runDir)...)
+	}
+
+	for _, queue := range queues {
+		suite := suites[queue]
+		suite.count()
+		doc.Suites = append(doc.Suites, *suite)
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+		doc.Errors += suite.Errors
+		doc.Skipped += suite.Skipped
+		doc.Time += suite.Time
+	}
+	return doc
+}
+
+// Write writes the JUnit document to w
+func Write(w io.Writer, doc TestSuites) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// AttemptCases returns the test cases of an attempt from its JUnit XML, or
+// from its exit code and log if it wrote none
+func AttemptCases(testID string, attempt results.Attempt, runDir string) []TestCase {
+	junitFile := filepath.Join(runDir, attempt.ArtifactDir, rundir.JUnitFileName(testID))
+	logFile := filepath.Join(runDir, attempt.LogFile)
+	suites, err := Parse(junitFile)
+	cases := Cases(suites)
+	if err != nil || len(cases) == 0 {
+		return []TestCase{synthesize(testID, attempt, logFile)}
+	}
+	for i := range cases {
+		cases[i].mask()
+	}
+	if len(cases) == 1 && cases[0].SystemOut == "" {
+		cases[0].SystemOut = tail(logFile)
+	}
+	return cases
+}
+
+// mask masks the secrets in the output and results the test framework wrote,
+// the output is dropped when the log output is left out of the reports
+func (c *TestCase) mask() {
+	if config.AppConfig.OmitLogs {
+		c.SystemOut, c.SystemErr = "", ""
+		for _, result := range []*Result{c.Failure, c.Error, c.Skipped} {
+			if result != nil {
+				result.Text = ""
+			}
+		}
+	}
+	c.SystemOut = logger.Mask(c.SystemOut)
+	c.SystemErr = logger.Mask(c.SystemErr)
+	for _, result := range []*Result{c.Failure, c.Error, c.Skipped} {
+		if result != nil {
+			result.Message = logger.Mask(result.Message)
+			result.Text = logger.Mask(result.Text)
+		}
+	}
+}
+
+func mergeAttempts(test results.Test, runDir string) []TestCase {
+	last := len(test.Attempts) - 1
+	final := AttemptCases(test.ID, test.Attempts[last], runDir)
+	index := make(map[string]int, len(final))
+	for i, c := range final {
+		index[c.key()] = i
+	}
+
+	for _, attempt := range test.Attempts[:last] {
+		for _, c := range AttemptCases(test.ID, attempt, runDir) {
+			if !c.Failed() {
+				continue
+			}
+			i, ok := index[c.key()]
+			if !ok {
+				// a synthesized case is named after the node ID, not after the
+				// class pytest reports, so it only matches a single final case
+				if len(final) != 1 {
+					continue
+				}
+				i = 0
+			}
+			final[i].addRerun(c, attempt.Number)
+		}
+	}
+	return final
+}
+
+// addRerun records an earlier failed attempt of the test case
+func (c *TestCase) addRerun(attempt TestCase, number int) {
+	result, isError := attempt.Failure, false
+	if result == nil {
+		result, isError = attempt.Error, true
+	}
+	rerun := Rerun{
+		Message:    fmt.Sprintf("attempt %d: %s", number, result.Message),
+		Type:       result.Type,
+		StackTrace: result.Text,
+		SystemOut:  attempt.SystemOut,
+		SystemErr:  attempt.SystemErr,
+	}
+	switch {
+	case !c.Failed() && isError:
+		c.FlakyErrors = append(c.FlakyErrors, rerun)
+	case !c.Failed():
+		c.FlakyFailures = append(c.FlakyFailures, rerun)
+	case isError:
+		c.RerunErrors = append(c.RerunErrors, rerun)
+	default:
+		c.RerunFailures = append(c.RerunFailures, rerun)
+	}
+}
+
+// synthesize returns the test case of an attempt that wrote no JUnit XML
+func synthesize(testID string, attempt results.Attempt, logFile string) TestCase {
+	className, name := splitNodeID(testID)
+	output := tail(logFile)
+	c := TestCase{ClassName: className, Name: name, Time: attempt.DurationSeconds, SystemOut: output}
+	switch attempt.Status {
+	case runstate.Passed:
+	case runstate.NotSelected:
+		c.Skipped = &Result{Message: "no test case selected"}
+	default:
+		c.Failure = &Result{
+			Message: fmt.Sprintf("%s with exit code %d", attempt.Status, attempt.ExitCode),
+			Type:    "itr." + string(attempt.Status),
+			Text:    output,
+		}
+	}
+	return c
+}
+
+// splitNodeID returns the pytest classname and name of a node ID, e.g.
+// tests/a.py::TestA::test_x is tests.a.TestA and test_x
+func splitNodeID(testID string) (string, string) {
+	parts := strings.Split(testID, "::")
+	module := strings.ReplaceAll(strings.TrimSuffix(parts[0], ".py"), "/", ".")
+	if len(parts) == 1 {
+		return "", module
+	}
+	return strings.Join(append([]string{module}, parts[1:len(parts)-1]...), "."), parts[len(parts)-1]
+}
+
+// count sets the totals of the suite from its test cases
+func (s *TestSuite) count() {
+	s.Tests = len(s.Cases)
+	for _, c := range s.Cases {
+		s.Time += c.Time
+		switch {
+		case c.Failure != nil:
+			s.Failures++
+		case c.Error != nil:
+			s.Errors++
+		case c.Skipped != nil:
+			s.Skipped++
+		}
+	}
+}
+
+// tail returns the end of the log file, nothing when the log output is left
+// out of the reports
+func tail(path string) string {
+	if config.AppConfig.OmitLogs {
+		return ""
+	}
+	return utils.LogTail(path, maxOutput, 0)
+}
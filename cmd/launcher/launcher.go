@@ -22,27 +22,27 @@ import (
 	"io"
 	"os"
 	"os/exec"
-	"path/filepath"
-	"runtime"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/vavuthu/itr/cmd/hooks"
+	"github.com/vavuthu/itr/cmd/junit"
 	"github.com/vavuthu/itr/cmd/mail"
 	"github.com/vavuthu/itr/cmd/payload"
 	"github.com/vavuthu/itr/cmd/report"
+	"github.com/vavuthu/itr/cmd/rundir"
+	"github.com/vavuthu/itr/cmd/runstate"
 	"github.com/vavuthu/itr/cmd/statusquo"
+	"github.com/vavuthu/itr/cmd/tracing"
+	"github.com/vavuthu/itr/cmd/tui"
 	"github.com/vavuthu/itr/config"
 	"github.com/vavuthu/itr/logger"
 )
 
-var failedTC = make(map[string]int)
-var logsDir string
-var exitCode int
-
-const noTestcasesSelected = "no_testcases_selected.txt"
-
+// exitCodeFailed is the exit code of ITR when any test case failed
+const exitCodeFailed = 3
 
 type execute interface {
 	Execute() error
@@ -52,18 +52,26 @@ type executeRetry interface {
 	execute
 	decreaseRetry()
 	retriesLeft() int
+	testID() string
 }
 
 type Command struct {
-	cmd     string
-	retries int
+	cmd      string
+	testCase string
+	env      []string
+	retries  int
+	attempt  int
 }
 
 func (c *Command) Execute() error  {
-	s := payload.LastString(strings.Split(c.cmd, "::"))
-	testCaseName := strings.Split(s, " ")[0]
-	logFile := filepath.Join(logsDir, testCaseName)
-	noTestcasesSelectedFilepath := filepath.Join(logsDir, noTestcasesSelected)
+	return c.execute(false)
+}
+
+// execute runs the next attempt of the test case, or its isolated rerun
+func (c *Command) execute(isolated bool) error {
+	testCase := c.testCase
+	c.attempt++
+	logFile := rundir.LogFile(testCase, c.attempt)
 
 	// Open a file for writing (create it if not exists, truncate if exists)
 	outputFile, err := os.Create(logFile)
@@ -73,18 +81,38 @@ func (c *Command) Execute() error  {
 	}
 	defer outputFile.Close()
 
-	cmdSplit := strings.Fields(c.cmd)
-	var testCase string
-	for _, each := range cmdSplit {
-		if isContains := strings.Contains(each, testCaseName); isContains {
-			testCase = each
-			break
+	if err := rundir.AddLogToIndex(testCase, logFile); err != nil {
+		logger.Errorf("Error updating log index: %v", err)
+	}
+
+	// the attempt counts as failed unless it reaches one of the results below
+	attemptStatus, attemptExitCode := runstate.Failed, -1
+	start, setResult, finish := runstate.Current.StartAttempt, runstate.Current.SetResult, runstate.Current.FinishAttempt
+	if isolated {
+		start, setResult, finish = runstate.Current.StartIsolation, runstate.Current.SetIsolationResult, runstate.Current.FinishIsolation
+	}
+	start(testCase, c.attempt, logFile, rundir.ArtifactDir(testCase, c.attempt))
+	defer func() {
+		if result, ok := junit.AttemptResult(testCase, c.attempt, logFile); ok {
+			setResult(testCase, result)
 		}
+		finish(testCase, attemptExitCode, attemptStatus)
+	}()
+
+	artifactDir, err := rundir.CreateArtifactDir(testCase, c.attempt)
+	if err != nil {
+		logger.Errorf("Error creating artifact directory: %v", err)
+		return fmt.Errorf("test case: %s failed", testCase)
+	}
+	run := fmt.Sprintf("attempt %d", c.attempt)
+	if isolated {
+		run = "isolated rerun"
 	}
-	logger.Infof("Running test case: %s and live log streamed at %s", testCase, outputFile.Name())
+	logger.Infof("Running test case: %s (%s) and live log streamed at %s, artifacts collected at %s", testCase, run, outputFile.Name(), artifactDir)
 	
-	parts := strings.Fields(c.cmd)
+	parts := strings.Fields(payload.ForAttempt(c.cmd, config.AppConfig.RunID, c.testCase, c.attempt, artifactDir))
 	podmanCmd := exec.Command(parts[0], parts[1:]...)
+	podmanCmd.Env = append(os.Environ(), c.env...)
 	podmanCmd.Stderr = podmanCmd.Stdout
 	stdoutPipe, err := podmanCmd.StdoutPipe()
 
@@ -98,39 +126,39 @@ func (c *Command) Execute() error  {
 		return fmt.Errorf("test case: %s failed", testCase)
 	}
 	
-	_, err = io.Copy(outputFile, stdoutPipe)
+	output := newActivityWriter(outputFile)
+	watchdog := newWatchdog(config.AppConfig.Hang, hooks.Context{
+		Event:       config.HookHang,
+		RunID:       config.AppConfig.RunID,
+		TestID:      testCase,
+		Attempt:     c.attempt,
+		Container:   payload.ContainerName(config.AppConfig.RunID, testCase, c.attempt),
+		LogFile:     logFile,
+		ArtifactDir: artifactDir,
+	}, output, podmanCmd)
+	watchdog.start()
+	_, err = io.Copy(output, stdoutPipe)
 	if err != nil {
+		watchdog.stop()
 		logger.Infof("Error in copying output to file: %v", err)
 		return fmt.Errorf("test case: %s failed", testCase)
 	}
 
 	err = podmanCmd.Wait()
+	watchdog.stop()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		attemptExitCode = exitErr.ExitCode()
+	}
+	if watchdog.hung.Load() {
+		attemptStatus = runstate.Hung
+		return fmt.Errorf("test case: %s hung", testCase)
+	}
 	if err != nil && err.Error() == "exit status 5" {
-		statusquo.TestCasesNotSelected++
-		logger.Infof("logfilename: %s", testCase)
-
-		// Open the file with flags for appending, creating if necessary, and setting the correct permissions
-		file, err := os.OpenFile(noTestcasesSelectedFilepath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			logger.Errorf("failed opening file %s: %s", noTestcasesSelected, err)
-		}
-		defer file.Close()
-
-		// Write the data to the file
-		_, err = file.WriteString(testCase + "\n")
-		if err != nil {
-			logger.Errorf("failed writing to file: %s", err)
-		}
-
+		attemptStatus = runstate.NotSelected
+		logger.Infof("no test case selected for %s", testCase)
 		return nil
 	} else if err != nil {
 		logger.Errorf("Error in waiting for command %v and test case is %s", err, testCase)
-		if c.retriesLeft() > 0 {
-			logFileName := outputFile.Name()
-			backupFile := generateEpochFileName(logFileName)
-			logger.Infof("moving log file %s to %s", logFileName, backupFile)
-			os.Rename(logFileName, backupFile)
-		}
 		return fmt.Errorf("test case: %s failed", testCase)
 	}
 
@@ -141,10 +169,7 @@ func (c *Command) Execute() error  {
 	}
 
 	logger.Infof("test case: %s executed successfully.", testCase)
-	statusquo.TestCasesPassed++
-
-	// if it passes, check test case present in failedTC and remove it if it present
-	delete(failedTC, c.cmd)
+	attemptStatus, attemptExitCode = runstate.Passed, 0
 
 	return nil
 }
@@ -157,65 +182,69 @@ func (c *Command) decreaseRetry() {
 	c.retries--
 }
 
+func (c *Command) testID() string {
+	return c.testCase
+}
+
 func (c *Command) String() string {
 	return c.cmd
 }
 
+// isolatedCommand reruns a test case that failed for good on its own, its
+// result classifies the failure instead of counting as an attempt
+type isolatedCommand struct {
+	command *Command
+}
+
+func (c isolatedCommand) Execute() error {
+	return c.command.execute(true)
+}
+
 type Launcher struct {
 	payload                []execute
-	failedTCAfterRetry     *os.File
 	payloadLock            sync.Mutex
 	wg                     sync.WaitGroup
+	running                atomic.Int32
 }
 
-func (l *Launcher) LaunchCommands(queueLength, retry int, stopChannel chan<- bool) {
+// next takes the next command from the payload
+func (l *Launcher) next() (execute, bool) {
+	l.payloadLock.Lock()
+	defer l.payloadLock.Unlock()
+
+	if len(l.payload) == 0 {
+		return nil, false
+	}
+	cmd := l.payload[0]
+	l.payload = l.payload[1:]
+	return cmd, true
+}
+
+func (l *Launcher) LaunchCommands(queueLength int, stopChannel chan<- bool) {
 	workerPool := make(chan struct{}, queueLength)
 
 	// Infinite loop to run commands till payload is completed
-	out:
 	for {
 		time.Sleep(time.Second)
-		if len(l.payload) == 0 {
-			if len(failedTC) > 0  {
-				// check if all test cases are retried with given Retries
-				for k, v := range failedTC {
-					if v != retry + 1 {
-						logger.Infof("tc %s doesn't run with max retries %d", k, retry)
-						time.Sleep(time.Second)
-						continue out
-
-					}
-				}
-				// shouldnot break for loop here because, lets say we have 3 TC's, and tc1 failed all times and
-				// still tc2 and tc3 running. In this case, we shouldnot break for loop, because if we break
-				// there might be chance tc2/tc3 will fail and it won't retry for retry times.
+		cmd, ok := l.next()
+		if !ok {
+			// failed test cases are added back to the payload for retry before
+			// they stop running, so the payload has to be empty once nothing runs
+			if l.running.Load() > 0 {
+				logger.Debug("still some test cases are running .....")
+				continue
 			}
-			if len(failedTC) == 0 && runtime.NumGoroutine() == 2 {
+			if cmd, ok = l.next(); !ok {
 				logger.Info("All the test cases are executed")
 				// Send stop signal
 				stopChannel <- true
 				break
 			}
-
-			// no payload and no failedTC's or no payload and all faileed TC retries time equal to max retry times
-			if runtime.NumGoroutine() > 2 {
-				logger.Info("still some test cases are running .....")
-				time.Sleep(time.Second)
-				continue out
-	
-			}
-			
-			// if we reach here which means, len(l.payload) == 0 and len(failedTC) > 0 and all TC's retries times equal to retry
-			break
 		}
 
-		l.payloadLock.Lock()
-		cmd := l.payload[0]
-		l.payload = l.payload[1:]
-		l.payloadLock.Unlock()
-
 		workerPool <- struct{}{}
 		l.wg.Add(1)
+		l.running.Add(1)
 		go l.LaunchExecute(workerPool, cmd)
 	}
 
@@ -225,39 +254,58 @@ func (l *Launcher) LaunchCommands(queueLength, retry int, stopChannel chan<- boo
 func (l *Launcher) LaunchExecute(workerPool chan struct{}, e execute) {
 	defer func()  {
 		<-workerPool
+		l.running.Add(-1)
 		l.wg.Done()
 	}()
 
 	if err := e.Execute(); err != nil {
 		logger.Warnf("%v", err)
 		if cmd, ok := e.(executeRetry); ok {
-			logger.Infof("Retries left for %s is %d", strings.Fields(err.Error())[2], cmd.retriesLeft())
+			logger.Infof("Retries left for %s is %d", cmd.testID(), cmd.retriesLeft())
 			if cmd.retriesLeft() > 0 {
+				runstate.Current.Retry(cmd.testID())
 				cmd.decreaseRetry()
 				l.payloadLock.Lock()
 				l.payload = append(l.payload, cmd)
 				l.payloadLock.Unlock()					
 			} else {
-				tc := strings.Fields(err.Error())[2]
-				tcWithNewLine := tc + "\n"
-				logger.Warnf("test case: %v exceeded maximum retries", tc)
-				statusquo.TestCasesFailed++
-				logger.Error("test case:", strings.Fields(err.Error())[2], "failed")
-				l.failedTCAfterRetry.Write([]byte(tcWithNewLine))
-				exitCode = 3
+				logger.Warnf("test case: %v exceeded maximum retries", cmd.testID())
+				runstate.Current.Fail(cmd.testID())
+				logger.Error("test case:", cmd.testID(), "failed")
 			}
 		}
 	}
 }
 
-func LaunchInitiate(commands []string, configDir string, queueLength, retry int) {
+// LaunchInitiate runs the commands of the queue, the test cases must have
+// been added to the run state already. After the last queue it generates the
+// reports, sends the email and exits.
+func LaunchInitiate(commands []payload.PodmanCommand, configDir, queue string, queueLength, retry int) {
 	logger.Info("Intiating Launch with queueLength: ", queueLength)
 	
-	logsDir = configDir
 	executor := []execute{}
 	for _, cmd := range commands {
-		executor = append(executor, &Command{cmd: cmd, retries: retry})
+		executor = append(executor, &Command{cmd: cmd.Cmd, testCase: cmd.TestCase, env: cmd.Env, retries: retry})
 	}
+	launch(executor, queue, queueLength)
+}
+
+// LaunchIsolated reruns the test cases of the commands one at a time without
+// retries, after their attempts. Like LaunchInitiate it finishes the run when
+// it is the last queue.
+func LaunchIsolated(commands []payload.PodmanCommand, queue string) {
+	executor := []execute{}
+	for _, cmd := range commands {
+		test, _ := runstate.Current.Test(cmd.TestCase)
+		executor = append(executor, isolatedCommand{command: &Command{cmd: cmd.Cmd, testCase: cmd.TestCase, env: cmd.Env, attempt: len(test.Attempts)}})
+	}
+	launch(executor, queue, 1)
+}
+
+// launch runs the commands of the queue, after the last queue it generates
+// the reports, sends the email and exits
+func launch(executor []execute, queue string, queueLength int) {
+	runstate.Current.SetQueueLength(queue, queueLength)
 
 	// Initialize the Launcher
 	launch := &Launcher{
@@ -275,49 +323,51 @@ func LaunchInitiate(commands []string, configDir string, queueLength, retry int)
 	var wg1 sync.WaitGroup
 	wg1.Add(1)
 	go statusquo.Statusquo(&wg1, stopChannel)
-	
-	failedTCAfterRetryFile := filepath.Join(logsDir, "failed_final_testcases.txt")
-	launch.failedTCAfterRetry, _ = os.Create(failedTCAfterRetryFile)
-	defer launch.failedTCAfterRetry.Close()
 
-	startTime := time.Now()
 	// Execute commands
-	launch.LaunchCommands(queueLength, retry, stopChannel)
+	launch.LaunchCommands(queueLength, stopChannel)
 	wg1.Wait()
 
 	if isSerialEngineNeeded, ok := config.AppConfig.Env["isSerialEngineNeeded"].(bool); !ok || !isSerialEngineNeeded {
 
-		endTime := time.Now()
-		totalTime := endTime.Sub(startTime)
+		runstate.Current.Finish()
+		tui.Stop()
+		summary := runstate.Current.Summary()
+
+		metadata, err := rundir.ReadMetadata()
+		if err == nil {
+			metadata.EndTime = summary.EndTime
+			err = rundir.WriteMetadata(metadata)
+		}
+		if err != nil {
+			logger.Errorf("Failed to update run metadata: %v", err)
+		}
 
 		// report generation
-		report.GenerateSummary(configDir)
-		report.GenerateHTMLReport(configDir, totalTime)
+		endPhase := tracing.Phase("report")
+		results := report.Collect(runstate.Current)
+		report.WriteResultFiles(results)
+		if config.AppConfig.HistoryDB != "" {
+			report.CompareWithHistory(results, config.AppConfig.HistoryDB, true)
+		}
+		endPhase(report.Generate(results, config.AppConfig.Reports))
 
 		if config.AppConfig.EmailID != "" {
+			endPhase = tracing.Phase("email")
 			mail.SendMail()
+			endPhase(nil)
 			logger.Info("Email sent successfully to ", config.AppConfig.EmailID)
 		}
+		tracing.Shutdown()
 
+		// the final statuses of the reports decide, not the exit codes of the attempts
+		exitCode := 0
+		for _, test := range results.Tests {
+			if test.Status.Failing() {
+				exitCode = exitCodeFailed
+			}
+		}
 		os.Exit(exitCode)
     }
 
 }
-
-// suffix epoch time for the file name
-func generateEpochFileName(filename string) string {
-	now := time.Now()
-	epochTimeStr := strconv.FormatInt(now.Unix(), 10)
-	backupFile := filename + "-" + epochTimeStr
-	return backupFile
-}
-
-func createFile(filename string) (*os.File, error) {
-	file, err := os.Create(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	return file, err
-}
@@ -0,0 +1,171 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package launcher
+
+import (
+	"io"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vavuthu/itr/cmd/hooks"
+	"github.com/vavuthu/itr/cmd/runstate"
+	"github.com/vavuthu/itr/config"
+	"github.com/vavuthu/itr/logger"
+)
+
+const (
+	minWatchdogInterval = time.Second
+	maxWatchdogInterval = 30 * time.Second
+)
+
+// activityWriter records the time of the last write to the log of an attempt
+type activityWriter struct {
+	w    io.Writer
+	last atomic.Int64
+}
+
+func newActivityWriter(w io.Writer) *activityWriter {
+	a := &activityWriter{w: w}
+	a.last.Store(time.Now().UnixNano())
+	return a
+}
+
+func (a *activityWriter) Write(p []byte) (int, error) {
+	a.last.Store(time.Now().UnixNano())
+	return a.w.Write(p)
+}
+
+func (a *activityWriter) lastWrite() time.Time {
+	return time.Unix(0, a.last.Load())
+}
+
+// watchdog flags the attempt as stalled when it writes no output for the
+// hang timeout and applies the hang policy
+type watchdog struct {
+	options config.HangOptions
+	hooks   []string
+	context hooks.Context
+	output  *activityWriter
+	process *exec.Cmd
+	hung    atomic.Bool
+	done    chan struct{}
+	// mu keeps kill from running once stop returned, the hang hooks run
+	// before kill may outlast the attempt
+	mu      sync.Mutex
+	stopped bool
+}
+
+func newWatchdog(options config.HangOptions, context hooks.Context, output *activityWriter, process *exec.Cmd) *watchdog {
+	hangHooks := config.AppConfig.Hooks[config.HookHang]
+	if len(hangHooks) == 0 {
+		hangHooks = hooks.DefaultHangHooks
+	}
+	return &watchdog{
+		options: options,
+		hooks:   hangHooks,
+		context: context,
+		output:  output,
+		process: process,
+		done:    make(chan struct{}),
+	}
+}
+
+// start watches the output until stop is called, it does nothing if the
+// hang detection is disabled
+func (w *watchdog) start() {
+	if w.options.Timeout <= 0 {
+		return
+	}
+	interval := w.options.Timeout / 10
+	if interval < minWatchdogInterval {
+		interval = minWatchdogInterval
+	}
+	if interval > maxWatchdogInterval {
+		interval = maxWatchdogInterval
+	}
+	go w.watch(interval)
+}
+
+func (w *watchdog) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	close(w.done)
+}
+
+func (w *watchdog) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	stalled := false
+	testID := w.context.TestID
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		lastWrite := w.output.lastWrite()
+		idle := time.Since(lastWrite)
+		if idle < w.options.Timeout {
+			if stalled {
+				logger.Infof("test case: %s writes output again", testID)
+				runstate.Current.Resume(testID)
+				stalled = false
+			}
+			continue
+		}
+		if stalled {
+			continue
+		}
+
+		stalled = true
+		logger.Warnf("test case: %s (attempt %d) wrote no output for %s, it may be hung", testID, w.context.Attempt, idle.Round(time.Second))
+		runstate.Current.Stall(testID, lastWrite)
+		if w.options.Policy == config.HangWarn {
+			continue
+		}
+		hooks.Run(w.hooks, w.context)
+		if w.options.Policy == config.HangKill {
+			w.kill()
+			return
+		}
+	}
+}
+
+// kill stops the container of the hung attempt, the podman process is killed
+// if the container can't be
+func (w *watchdog) kill() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		logger.Infof("test case: %s (attempt %d) finished while its hang hooks ran, not killing it", w.context.TestID, w.context.Attempt)
+		return
+	}
+	w.hung.Store(true)
+	logger.Warnf("Killing hung test case: %s (attempt %d)", w.context.TestID, w.context.Attempt)
+	if out, err := exec.Command("podman", "kill", w.context.Container).CombinedOutput(); err != nil {
+		logger.Errorf("Failed to kill container %s: %v: %s", w.context.Container, err, out)
+		if err := w.process.Process.Kill(); err != nil {
+			logger.Errorf("Failed to kill podman process of test case %s: %v", w.context.TestID, err)
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/vavuthu/itr/config"
 	"github.com/wneessen/go-mail"
+	"github.com/vavuthu/itr/cmd/rundir"
 	"github.com/vavuthu/itr/cmd/utils"
 	"github.com/vavuthu/itr/logger"
 	
@@ -34,7 +35,7 @@ const (
 
 func SendMail() {
 	emailID := config.AppConfig.EmailID
-	htmlReport := "report_" + config.AppConfig.RunID + ".html"
+	htmlReport := rundir.HTMLReport()
 	htmlContent, err := os.ReadFile(htmlReport)
 	if err != nil {
 		logger.Errorf("Failed to read HTML file: %s", htmlReport)
@@ -19,19 +19,33 @@ package payload
 
 import (
 	"strings"
+
+	"github.com/vavuthu/itr/config"
 )
 
 const (
-	prefix = "podman run -e BUILD_NUMBER -e BUILD_TAG -e BUILD_URL -e JOB_NAME -e NODE_NAME -e WORKSPACE --rm -v "
+	prefix = "podman run --rm "
 	PodmanPath = "/opt/cluster"
+	// PodmanArtifactsPath is where the artifact directory of the attempt is mounted
+	PodmanArtifactsPath = "/opt/artifacts"
+	// ArtifactsEnv is set to PodmanArtifactsPath in the container
+	ArtifactsEnv = "ITR_ARTIFACTS_DIR"
 	podmanSharedMountOption = "z "
 	redirectionOperator = " >"
 	redirectSTDERROUT = " 2>&1"
 	background = " &"
 )
 
+// PodmanCommand is the podman command of a test case along with the
+// environment which has to be set on the podman process
+type PodmanCommand struct {
+	TestCase string
+	Cmd      string
+	Env      []string
+}
+
 // Forms the podman command for test case
-func CommandGenerator(testCase, testCaseName, image, configDir string) string {
-	command := prefix + configDir + ":" + PodmanPath + ":" + podmanSharedMountOption + image + " " + strings.TrimSuffix(testCase, "\n")
+func CommandGenerator(testCase, testCaseName, image, configDir string, opts config.ContainerOptions, envNames []string) string {
+	command := prefix + attemptPlaceholder + EnvArgs(envNames) + ContainerArgs(opts) + "-v " + configDir + ":" + PodmanPath + ":" + podmanSharedMountOption + image + " " + strings.TrimSuffix(testCase, "\n")
 	return command
 }
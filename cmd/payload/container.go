@@ -0,0 +1,51 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package payload
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/vavuthu/itr/config"
+)
+
+// ContainerArgs forms the podman run options for the given container options
+func ContainerArgs(opts config.ContainerOptions) string {
+	var args []string
+	if opts.CPUs != "" {
+		args = append(args, "--cpus", opts.CPUs)
+	}
+	if opts.Memory != "" {
+		args = append(args, "--memory", opts.Memory)
+	}
+	if opts.PidsLimit != 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(opts.PidsLimit))
+	}
+	if opts.Network != "" {
+		args = append(args, "--network", opts.Network)
+	}
+	for _, volume := range opts.Volumes {
+		args = append(args, "-v", volume)
+	}
+	args = append(args, opts.ExtraArgs...)
+
+	if len(args) == 0 {
+		return ""
+	}
+	return strings.Join(args, " ") + " "
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package payload
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/vavuthu/itr/config"
+)
+
+// ContainerEnv returns the names of the variables passed to the test container
+// and the NAME=VALUE pairs which have to be set on the podman process for them.
+// Only names are given to podman so the values never show up in the command.
+func ContainerEnv(envOpts config.EnvOptions, testEnv map[string]string) ([]string, []string) {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, entry := range envOpts.Passthrough {
+		if !strings.HasSuffix(entry, "*") {
+			add(entry)
+		}
+	}
+
+	var inherited []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if envOpts.MatchesPassthrough(name) {
+			inherited = append(inherited, name)
+		}
+	}
+	sort.Strings(inherited)
+	for _, name := range inherited {
+		add(name)
+	}
+
+	explicit := make(map[string]string)
+	for k, v := range envOpts.Env {
+		explicit[k] = v
+	}
+	for k, v := range testEnv {
+		explicit[k] = v
+	}
+	keys := make([]string, 0, len(explicit))
+	for k := range explicit {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var values []string
+	for _, k := range keys {
+		add(k)
+		values = append(values, k+"="+explicit[k])
+	}
+
+	return names, values
+}
+
+// EnvArgs forms the podman -e options for the given variable names
+func EnvArgs(names []string) string {
+	var args string
+	for _, name := range names {
+		args += "-e " + name + " "
+	}
+	return args
+}
@@ -0,0 +1,69 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package payload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// LabelRunID marks every container started by ITR with the run ID
+	LabelRunID = "itr.run-id"
+	LabelTestID = "itr.test-id"
+	LabelAttempt = "itr.attempt"
+	// attemptPlaceholder is replaced with the name, labels and artifact
+	// mount of the container when the attempt is launched
+	attemptPlaceholder = "<ITR_ATTEMPT_ARGS> "
+	maxNameSegment = 40
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// ContainerName returns the unique container name of a test case attempt
+func ContainerName(runID, testCase string, attempt int) string {
+	sum := sha256.Sum256([]byte(testCase))
+	name := invalidNameChars.ReplaceAllString(LastString(strings.Split(testCase, "::")), "_")
+	if len(name) > maxNameSegment {
+		name = name[:maxNameSegment]
+	}
+	return "itr-" + invalidNameChars.ReplaceAllString(runID, "_") + "-" + name + "-" + hex.EncodeToString(sum[:4]) + "-" + strconv.Itoa(attempt)
+}
+
+// LabelArgs forms the podman --name and --label options of a test case attempt
+func LabelArgs(runID, testCase string, attempt int) string {
+	testID := strings.Join(strings.Fields(testCase), "_")
+	return "--name " + ContainerName(runID, testCase, attempt) +
+		" --label " + LabelRunID + "=" + runID +
+		" --label " + LabelTestID + "=" + testID +
+		" --label " + LabelAttempt + "=" + strconv.Itoa(attempt) + " "
+}
+
+// ArtifactArgs forms the podman options mounting the artifact directory of an attempt
+func ArtifactArgs(artifactDir string) string {
+	return "-v " + artifactDir + ":" + PodmanArtifactsPath + ":" + podmanSharedMountOption +
+		"-e " + ArtifactsEnv + "=" + PodmanArtifactsPath + " "
+}
+
+// ForAttempt fills the container name, labels and artifact mount of the attempt in the podman command
+func ForAttempt(cmd, runID, testCase string, attempt int, artifactDir string) string {
+	return strings.Replace(cmd, attemptPlaceholder, LabelArgs(runID, testCase, attempt)+ArtifactArgs(artifactDir), 1)
+}
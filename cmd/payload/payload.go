@@ -21,6 +21,8 @@ import (
 	"os"
 	"strings"
 
+	"github.com/vavuthu/itr/cmd/rundir"
+	"github.com/vavuthu/itr/config"
 	"github.com/vavuthu/itr/logger"
 )
 
@@ -32,17 +34,18 @@ var (
 func FormPayload(basePayload, testCase, testCaseName, configDir string, junitXML bool) string {
 	basePayload = strings.ReplaceAll(basePayload, "<MY_TEST_CASE>", testCase)
 	basePayload  = strings.ReplaceAll(basePayload, configDir, PodmanPath)
+	basePayload = strings.ReplaceAll(basePayload, "<"+ArtifactsEnv+">", PodmanArtifactsPath)
 	if junitXML {
-		junitFile := PodmanPath + "/" + testCaseName + ".xml"
+		junitFile := PodmanArtifactsPath + "/" + testCaseName + ".xml"
 		basePayload += " --junit-xml " + junitFile
 	}
 	return basePayload
 }
 
 // GenerateAllPodmanCommands generates podman commands for all test cases.
-func GenerateAllPodmanCommands(execution, configDir, nonDisruptiveTestCases, image string, junitXML bool) []string {
+func GenerateAllPodmanCommands(execution, configDir, nonDisruptiveTestCases, image string, junitXML bool, opts config.ContainerOptions, envOpts config.EnvOptions, manifest *config.Manifest) []PodmanCommand {
 
-	var commands []string
+	var commands []PodmanCommand
 
 	// Read the content of the file
 	content, err := os.ReadFile(nonDisruptiveTestCases)
@@ -63,10 +66,13 @@ func GenerateAllPodmanCommands(execution, configDir, nonDisruptiveTestCases, ima
 
 	// Replace <MY_TEST_CASE> with actual test case names
 	for _, testCase := range testCaseNames {
-		testCaseName := LastString(strings.Split(testCase, "::"))
+		testCaseName := rundir.FileName(testCase)
 		modifiedContent := FormPayload(string(executionContent), testCase, testCaseName, configDir, junitXML)
-		podmanCommand := CommandGenerator(modifiedContent, testCaseName, image, configDir)
-		commands = append(commands, podmanCommand)
+		testConfig := manifest.ForTest(testCase)
+		testOpts := opts.Merge(testConfig.Container)
+		envNames, envValues := ContainerEnv(envOpts, testConfig.Env)
+		podmanCommand := CommandGenerator(modifiedContent, testCaseName, image, configDir, testOpts, envNames)
+		commands = append(commands, PodmanCommand{TestCase: testCase, Cmd: podmanCommand, Env: envValues})
 	}
 
 	return commands
@@ -0,0 +1,214 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/vavuthu/itr/cmd/history"
+	"github.com/vavuthu/itr/cmd/mail"
+	"github.com/vavuthu/itr/cmd/report"
+	"github.com/vavuthu/itr/cmd/results"
+	"github.com/vavuthu/itr/cmd/rundir"
+	"github.com/vavuthu/itr/config"
+	"github.com/vavuthu/itr/logger"
+)
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report <run-dir>",
+	Short: "Regenerate the reports of a run from its run directory",
+	Long: `Regenerate the reports of a run from its run directory, e.g. in another format or after the run crashed.
+The results are read from results/results.json, or replayed from the event log and the test case logs when the
+run ended before writing them. Without --format the reports the run was started with are generated.`,
+	Args: cobra.ExactArgs(1),
+	Run:  reportRunCmd,
+}
+
+var (
+	reportEmail				string
+	reportFormats				[]string
+	reportHistoryDB				string
+	reportManifest				string
+	reportSubject				string
+)
+
+func init() {
+	reportCmd.Flags().StringArrayVar(&reportFormats, "format", nil, "report generated in FORMAT[=PATH] form like --report of a run (default the reports of the run)")
+	reportCmd.Flags().StringVar(&reportHistoryDB, "history-db", "", "history database the run is compared with (default history.db next to the run directory), none disables it")
+	reportCmd.Flags().StringVarP(&reportManifest, "manifest", "f", "", "manifest the values of the secret variables of the run are read from (default the manifest of the run)")
+	reportCmd.Flags().StringVarP(&reportEmail, "email", "m", "", "email to send the HTML report to")
+	reportCmd.Flags().StringVarP(&reportSubject, "subject", "s", "", "email subject")
+	rootCmd.AddCommand(reportCmd)
+}
+
+func reportRunCmd(cmd *cobra.Command, args []string) {
+	runDir := filepath.Clean(args[0])
+	config.AppConfig.OutputDir = filepath.Dir(runDir)
+	config.AppConfig.RunID = filepath.Base(runDir)
+	config.AppConfig.EmailID = reportEmail
+	config.AppConfig.Subject = reportSubject
+
+	reports, err := config.ParseReports(reportFormats)
+	if err == nil {
+		err = report.Validate(reports)
+	}
+	if err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+
+	results, err := report.Load()
+	if err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+	if len(reports) == 0 {
+		reports, err = recordedReports(results.Run)
+		if err != nil {
+			logger.Errorf("Failed to read the reports of run %s: %v", results.Run.ID, err)
+			os.Exit(1)
+		}
+	}
+	if len(reports) == 0 {
+		_, err := os.Stat(rundir.JUnitReport())
+		reports = report.DefaultReports(err == nil)
+	}
+	if reportEmail != "" {
+		reports = addHTMLReport(reports)
+	}
+	config.AppConfig.ConfigDir = results.Run.ConfigDir
+	if missing := registerRunSecrets(results.Run); len(missing) != 0 {
+		logger.Warnf("No value found for the secret variables %s of run %s, the log output is left out of the reports",
+			strings.Join(missing, ", "), results.Run.ID)
+		config.AppConfig.OmitLogs = true
+	}
+	if reportHistoryDB != history.Disabled {
+		report.CompareWithHistory(results, history.Path(reportHistoryDB, config.AppConfig.OutputDir), false)
+	}
+	if err := report.Generate(results, reports); err != nil {
+		os.Exit(1)
+	}
+
+	if reportEmail != "" {
+		mail.SendMail()
+		logger.Info("Email sent successfully to ", reportEmail)
+	}
+}
+
+// recordedArgs returns the arguments the run was started with
+func recordedArgs(run results.Run) []string {
+	args, err := rundir.ReadArgs()
+	if err != nil {
+		args = run.Args
+	}
+	return args
+}
+
+// recordedFlags returns the flags of the recorded arguments of the run, the
+// ones not defined in flags are ignored
+func recordedFlags(run results.Run, flags *pflag.FlagSet) error {
+	args := recordedArgs(run)
+	if len(args) == 0 {
+		return nil
+	}
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	return flags.Parse(args[1:])
+}
+
+// recordedReports returns the reports given with --report when the run was
+// started, none if it had the default ones
+func recordedReports(run results.Run) ([]config.Report, error) {
+	var pairs []string
+	flags := pflag.NewFlagSet(run.ID, pflag.ContinueOnError)
+	flags.StringArrayVar(&pairs, "report", nil, "")
+	if err := recordedFlags(run, flags); err != nil {
+		return nil, err
+	}
+	reports, err := config.ParseReports(pairs)
+	if err != nil {
+		return nil, err
+	}
+	return reports, report.Validate(reports)
+}
+
+// registerRunSecrets masks the values of the secret variables the run recorded
+// the names of, read from the environment, env files and manifest it was
+// started with and ITR's own environment, and returns the names of the ones
+// without a value
+func registerRunSecrets(run results.Run) []string {
+	metadata, err := rundir.ReadMetadata()
+	if err != nil || len(metadata.Secrets) == 0 {
+		return nil
+	}
+
+	var envPairs, envFiles []string
+	var manifestFile string
+	flags := pflag.NewFlagSet(run.ID, pflag.ContinueOnError)
+	flags.StringArrayVar(&envPairs, "env", nil, "")
+	flags.StringArrayVar(&envFiles, "env-file", nil, "")
+	flags.StringVarP(&manifestFile, "manifest", "f", "", "")
+	if err := recordedFlags(run, flags); err != nil {
+		logger.Warnf("Failed to read the arguments of run %s: %v", run.ID, err)
+	}
+	if reportManifest != "" {
+		manifestFile = reportManifest
+	}
+
+	var sources []map[string]string
+	if manifest, err := config.LoadManifest(manifestFile); err != nil {
+		logger.Warnf("Failed to read the manifest of run %s: %v", run.ID, err)
+	} else {
+		sources = append(sources, manifest.Env)
+		for _, testConfig := range manifest.Tests {
+			sources = append(sources, testConfig.Env)
+		}
+	}
+	for _, envFile := range envFiles {
+		if env, err := config.ParseEnvFile(envFile); err == nil {
+			sources = append(sources, env)
+		}
+	}
+	if env, err := config.ParseEnvPairs(envPairs); err == nil {
+		sources = append(sources, env)
+	}
+
+	var missing []string
+	for _, name := range metadata.Secrets {
+		found := false
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			logger.AddSecret(value)
+			found = true
+		}
+		for _, env := range sources {
+			// the arguments in the metadata have the values masked
+			if value, ok := env[name]; ok && value != "" && !logger.IsMasked(value) {
+				logger.AddSecret(value)
+				found = true
+			}
+		}
+		if !found {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,94 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vavuthu/itr/cmd/history"
+)
+
+// changeList is a list of test cases that changed since the previous run
+type changeList struct {
+	Title string
+	Class string
+	Tests []string
+}
+
+// changeLists returns the non empty lists of changes, the ones to look at first
+func changeLists(changes *history.Changes) []changeList {
+	lists := []changeList{
+		{Title: "New failures", Class: "failed", Tests: changes.NewFailures},
+		{Title: "Fixed", Class: "passed", Tests: changes.Fixed},
+		{Title: "Still failing", Class: "error", Tests: changes.StillFailing},
+		{Title: "Newly flaky", Class: "skipped", Tests: changes.NewlyFlaky},
+	}
+	var nonEmpty []changeList
+	for _, list := range lists {
+		if len(list.Tests) > 0 {
+			nonEmpty = append(nonEmpty, list)
+		}
+	}
+	return nonEmpty
+}
+
+// flakyRateChange describes the flaky rate compared with the previous run
+func flakyRateChange(changes *history.Changes) string {
+	return fmt.Sprintf("%.1f%% (was %.1f%%)", changes.FlakyRate*100, changes.PreviousFlakyRate*100)
+}
+
+// changeLines returns the changes since the previous run for the console
+func changeLines(changes *history.Changes) []string {
+	lines := []string{
+		fmt.Sprintf("Compared with run %s: %d new failures, %d fixed, %d still failing, flaky rate %s",
+			changes.PreviousRunID, len(changes.NewFailures), len(changes.Fixed), len(changes.StillFailing), flakyRateChange(changes)),
+	}
+	for _, list := range changeLists(changes) {
+		lines = append(lines, list.Title+":")
+		for _, test := range list.Tests {
+			lines = append(lines, "  "+test)
+		}
+	}
+	return lines
+}
+
+// writeMarkdownChanges writes the changes since the previous run
+func writeMarkdownChanges(b *strings.Builder, changes *history.Changes) {
+	fmt.Fprintf(b, "\n### Compared with run %s\n\n", changes.PreviousRunID)
+	fmt.Fprintf(b, "Flaky rate %s\n", flakyRateChange(changes))
+	for _, list := range changeLists(changes) {
+		fmt.Fprintf(b, "\n**%s (%d)**\n\n", list.Title, len(list.Tests))
+		for i, test := range list.Tests {
+			if i == markdownFailures {
+				fmt.Fprintf(b, "- and %d more\n", len(list.Tests)-markdownFailures)
+				break
+			}
+			fmt.Fprintf(b, "- `%s`\n", test)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
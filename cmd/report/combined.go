@@ -0,0 +1,118 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package report
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/vavuthu/itr/cmd/results"
+	"github.com/vavuthu/itr/cmd/rundir"
+	"github.com/vavuthu/itr/logger"
+)
+
+// combine sets the combined verdict of a rerun over the runs it reran, their
+// run directories are next to the one of the rerun
+func combine(r *Results) {
+	if r.Run.RerunOf == "" {
+		return
+	}
+	docs := []results.Document{r.Document}
+	seen := map[string]bool{r.Run.ID: true}
+	for id := r.Run.RerunOf; id != "" && !seen[id]; id = docs[0].Run.RerunOf {
+		seen[id] = true
+		doc, err := results.Read(rundir.ResultsFileOf(filepath.Join(filepath.Dir(r.RunDir), id)))
+		if err != nil {
+			logger.Errorf("Failed to read the results of run %s, reran by run %s: %v", id, r.Run.ID, err)
+			return
+		}
+		docs = append([]results.Document{doc}, docs...)
+	}
+	combined := results.Combine(docs)
+	r.Combined = &combined
+}
+
+// combinedVerdict describes the combined verdict and the runs it covers
+func combinedVerdict(combined *results.Combined) string {
+	verdict := "passed"
+	if combined.Failed() {
+		verdict = "failed"
+	}
+	return fmt.Sprintf("%s over runs %s", verdict, strings.Join(combined.RunIDs, ", "))
+}
+
+// combinedCounts returns the combined counts of the final statuses that occur
+func combinedCounts(combined *results.Combined) []string {
+	var counts []string
+	for _, status := range reportStatuses {
+		if count := combined.Statuses[status]; count > 0 {
+			counts = append(counts, fmt.Sprintf("%s: %d", statusLabels[status], count))
+		}
+	}
+	return counts
+}
+
+// combinedLists returns the non empty lists of the combined verdict
+func combinedLists(combined *results.Combined) []changeList {
+	lists := []changeList{
+		{Title: "Still failing", Class: "failed", Tests: combined.Failing},
+		{Title: "Passed on rerun", Class: "passed", Tests: combined.PassedOnRerun},
+	}
+	var nonEmpty []changeList
+	for _, list := range lists {
+		if len(list.Tests) > 0 {
+			nonEmpty = append(nonEmpty, list)
+		}
+	}
+	return nonEmpty
+}
+
+// combinedLines returns the combined verdict for the console
+func combinedLines(combined *results.Combined) []string {
+	lines := []string{
+		fmt.Sprintf("Combined verdict %s: %d test cases, %s", combinedVerdict(combined), combined.Total, strings.Join(combinedCounts(combined), ", ")),
+	}
+	for _, list := range combinedLists(combined) {
+		lines = append(lines, list.Title+":")
+		for _, test := range list.Tests {
+			lines = append(lines, "  "+test)
+		}
+	}
+	return lines
+}
+
+// writeMarkdownCombined writes the combined verdict
+func writeMarkdownCombined(b *strings.Builder, combined *results.Combined) {
+	verdict := "✅ Passed"
+	if combined.Failed() {
+		verdict = "❌ Failed"
+	}
+	fmt.Fprintf(b, "\n### Combined verdict\n\n**%s** over runs %s · %d test cases · %s\n",
+		verdict, strings.Join(combined.RunIDs, ", "), combined.Total, strings.Join(combinedCounts(combined), " · "))
+	for _, list := range combinedLists(combined) {
+		fmt.Fprintf(b, "\n**%s (%d)**\n\n", list.Title, len(list.Tests))
+		for i, test := range list.Tests {
+			if i == markdownFailures {
+				fmt.Fprintf(b, "- and %d more\n", len(list.Tests)-markdownFailures)
+				break
+			}
+			fmt.Fprintf(b, "- `%s`\n", test)
+		}
+	}
+}
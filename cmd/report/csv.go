@@ -0,0 +1,58 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/vavuthu/itr/cmd/rundir"
+)
+
+// csvReporter writes a row per test case for spreadsheets
+type csvReporter struct{}
+
+func init() {
+	Register("csv", csvReporter{})
+}
+
+func (csvReporter) DefaultPath() string {
+	return rundir.CSVReport()
+}
+
+func (csvReporter) Report(results *Results, path string) error {
+	return write(path, func(w io.Writer) error {
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"test_id", "queue", "status", "message", "duration_seconds", "attempts", "flaky", "failure_class"})
+		for _, test := range results.Tests {
+			writer.Write([]string{
+				test.ID,
+				test.Queue,
+				string(test.Status),
+				testMessage(test),
+				strconv.FormatFloat(testDuration(test).Seconds(), 'f', 3, 64),
+				strconv.Itoa(len(test.Attempts)),
+				strconv.FormatBool(test.Flaky),
+				test.FailureClass,
+			})
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+}
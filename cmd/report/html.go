@@ -0,0 +1,295 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package report
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/vavuthu/itr/cmd/results"
+	"github.com/vavuthu/itr/cmd/rundir"
+	"github.com/vavuthu/itr/cmd/utils"
+	"github.com/vavuthu/itr/config"
+)
+
+const (
+	// logTailLines is the number of lines of the failure log shown in the report
+	logTailLines = 100
+	logTailBytes = 32 * 1024
+)
+
+// htmlTemplate is a self-contained page, sorting and filtering work offline
+//
+//go:embed report.html
+var htmlTemplate string
+
+var reportTemplate = template.Must(template.New("report").Parse(htmlTemplate))
+
+type htmlReport struct {
+	RunID            string
+	Generated        string
+	Total            int
+	Minutes          float64
+	Counts           []htmlCount
+	Environment      []htmlRow
+	ContainerOptions []htmlRow
+	ContainerEnv     []htmlEnvRow
+	Tests            []htmlTest
+	// Isolation lists the test cases rerun in isolation by failure class
+	Isolation        []changeList
+	// Combined verdict of a rerun, nil for other runs
+	Combined         *htmlCombined
+	// Changes since the previous run of the suite, nil without history
+	Changes          *htmlChanges
+}
+
+type htmlChanges struct {
+	PreviousRunID string
+	FlakyRate     string
+	Lists         []changeList
+}
+
+type htmlCombined struct {
+	Verdict string
+	Total   int
+	Counts  []string
+	Lists   []changeList
+}
+
+type htmlCount struct {
+	Status string
+	Label  string
+	Count  int
+}
+
+type htmlRow struct {
+	Key   string
+	Value string
+}
+
+type htmlEnvRow struct {
+	Scope string
+	Name  string
+	Value string
+}
+
+type htmlTest struct {
+	ID              string
+	Queue           string
+	Status          string
+	Label           string
+	Message         string
+	Duration        string
+	DurationSeconds float64
+	Attempts        []htmlAttempt
+	LogTail         string
+	NewFailure      bool
+	// FailureClass describes the result of the isolated rerun
+	FailureClass    string
+	Isolation       *htmlAttempt
+}
+
+type htmlAttempt struct {
+	Number    int
+	Isolated  bool
+	Status    string
+	Result    string
+	Message   string
+	ExitCode  int
+	Duration  string
+	Log       string
+	Artifacts string
+	Files     []htmlRow
+}
+
+// htmlReporter writes a self-contained page
+type htmlReporter struct{}
+
+func init() {
+	Register("html", htmlReporter{})
+}
+
+func (htmlReporter) DefaultPath() string {
+	return rundir.HTMLReport()
+}
+
+func (htmlReporter) Report(results *Results, path string) error {
+	report := htmlReport{
+		RunID:       results.Run.ID,
+		Generated:   time.Now().Format(time.RFC1123),
+		Total:       results.Summary.Total,
+		Minutes:     results.Run.DurationSeconds / 60,
+		Environment: sortedRows(results.Run.Environment),
+	}
+	for _, status := range finalStatuses {
+		report.Counts = append(report.Counts, htmlCount{Status: string(status), Label: statusLabels[status], Count: results.Summary.Statuses[status]})
+	}
+	for _, status := range unfinishedStatuses {
+		if count := results.Summary.Statuses[status]; count > 0 {
+			report.Counts = append(report.Counts, htmlCount{Status: string(status), Label: statusLabels[status], Count: count})
+		}
+	}
+	report.ContainerOptions, report.ContainerEnv = containerRows(results.Run)
+	report.Isolation = isolationLists(results.Tests)
+	if combined := results.Combined; combined != nil {
+		report.Combined = &htmlCombined{Verdict: combinedVerdict(combined), Total: combined.Total, Counts: combinedCounts(combined), Lists: combinedLists(combined)}
+	}
+	if changes := results.Changes; changes != nil {
+		report.Changes = &htmlChanges{PreviousRunID: changes.PreviousRunID, FlakyRate: flakyRateChange(changes), Lists: changeLists(changes)}
+	}
+
+	// links are relative to the report so the run directory can be moved
+	linkDir := filepath.Dir(path)
+	if path == Stdout {
+		linkDir = "."
+	}
+	for _, test := range byStatus(results.Tests) {
+		report.Tests = append(report.Tests, newHTMLTest(test, results, linkDir))
+	}
+
+	return write(path, func(w io.Writer) error {
+		return reportTemplate.Execute(w, report)
+	})
+}
+
+func newHTMLTest(test results.Test, r *Results, linkDir string) htmlTest {
+	duration := testDuration(test)
+	t := htmlTest{
+		ID:              test.ID,
+		Queue:           test.Queue,
+		Status:          string(test.Status),
+		Label:           statusLabels[test.Status],
+		Message:         testMessage(test),
+		Duration:        formatDuration(duration),
+		DurationSeconds: duration.Seconds(),
+	}
+
+	for _, attempt := range test.Attempts {
+		t.Attempts = append(t.Attempts, newHTMLAttempt(attempt, r, linkDir))
+	}
+	if test.Isolation != nil {
+		isolation := newHTMLAttempt(*test.Isolation, r, linkDir)
+		isolation.Isolated = true
+		t.Isolation = &isolation
+		t.FailureClass = failureClassLabels[test.FailureClass]
+	}
+
+	if r.Changes != nil {
+		t.NewFailure = contains(r.Changes.NewFailures, test.ID)
+	}
+	if n := len(test.Attempts); n > 0 && test.Status.Failing() {
+		t.LogTail = logTail(r.Path(test.Attempts[n-1].LogFile), logTailLines)
+	}
+	return t
+}
+
+func newHTMLAttempt(attempt results.Attempt, r *Results, linkDir string) htmlAttempt {
+	a := htmlAttempt{
+		Number:    attempt.Number,
+		Status:    string(attempt.Status),
+		ExitCode:  attempt.ExitCode,
+		Duration:  formatDuration(time.Duration(attempt.DurationSeconds * float64(time.Second))),
+		Log:       reportLink(r.Path(attempt.LogFile), linkDir),
+		Artifacts: reportLink(r.Path(attempt.ArtifactDir), linkDir),
+	}
+	if attempt.Result != nil {
+		a.Result = string(attempt.Result.Status)
+		a.Message = attempt.Result.Message
+	}
+	if attempt.ArtifactDir != "" {
+		entries, _ := os.ReadDir(r.Path(attempt.ArtifactDir))
+		for _, entry := range entries {
+			a.Files = append(a.Files, htmlRow{Key: entry.Name(), Value: a.Artifacts + "/" + entry.Name()})
+		}
+	}
+	return a
+}
+
+// containerRows returns the run and per test case container options and environment
+func containerRows(run results.Run) ([]htmlRow, []htmlEnvRow) {
+	if run.Container == nil {
+		return []htmlRow{{Key: "Run", Value: "not recorded"}}, nil
+	}
+	options := []htmlRow{{Key: "Run", Value: describeContainerOptions(run.Container.Options)}}
+	var env []htmlEnvRow
+	for _, entry := range run.Container.Passthrough {
+		env = append(env, htmlEnvRow{Scope: "Run", Name: entry, Value: "passed through"})
+	}
+	for _, row := range sortedRows(run.Container.Env) {
+		env = append(env, htmlEnvRow{Scope: "Run", Name: row.Key, Value: row.Value})
+	}
+
+	testCases := make([]string, 0, len(run.TestContainers))
+	for testCase := range run.TestContainers {
+		testCases = append(testCases, testCase)
+	}
+	sort.Strings(testCases)
+	for _, testCase := range testCases {
+		container := run.TestContainers[testCase]
+		if !container.Options.IsEmpty() {
+			options = append(options, htmlRow{Key: testCase, Value: describeContainerOptions(container.Options)})
+		}
+		for _, row := range sortedRows(container.Env) {
+			env = append(env, htmlEnvRow{Scope: testCase, Name: row.Key, Value: row.Value})
+		}
+	}
+	return options, env
+}
+
+// sortedRows returns the entries of the map sorted by key
+func sortedRows(m map[string]string) []htmlRow {
+	rows := make([]htmlRow, 0, len(m))
+	for k, v := range m {
+		rows = append(rows, htmlRow{Key: k, Value: v})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Key < rows[j].Key })
+	return rows
+}
+
+// reportLink returns the path relative to the directory of the report
+func reportLink(path, linkDir string) string {
+	if path == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	dir, err := filepath.Abs(linkDir)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// logTail returns the last lines of the log file, nothing when the log output
+// is left out of the reports
+func logTail(path string, maxLines int) string {
+	if config.AppConfig.OmitLogs {
+		return ""
+	}
+	return utils.LogTail(path, logTailBytes, maxLines)
+}
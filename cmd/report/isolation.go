@@ -0,0 +1,92 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vavuthu/itr/cmd/results"
+)
+
+// failureClassLabels are the names of the failure classes in the reports
+var failureClassLabels = map[string]string{
+	results.FailsOnlyInParallel: "fails only under parallelism",
+	results.FailsInIsolation:    "fails in isolation too",
+}
+
+// isolationLists returns the non empty lists of the test cases rerun in
+// isolation by failure class, nil if none was
+func isolationLists(tests []results.Test) []changeList {
+	lists := []changeList{
+		{Title: "Fails only under parallelism", Class: "skipped"},
+		{Title: "Fails in isolation too", Class: "failed"},
+		{Title: "Inconclusive in isolation", Class: "not_selected"},
+	}
+	for _, test := range tests {
+		if test.Isolation == nil {
+			continue
+		}
+		switch test.FailureClass {
+		case results.FailsOnlyInParallel:
+			lists[0].Tests = append(lists[0].Tests, test.ID)
+		case results.FailsInIsolation:
+			lists[1].Tests = append(lists[1].Tests, test.ID)
+		default:
+			lists[2].Tests = append(lists[2].Tests, test.ID)
+		}
+	}
+	var nonEmpty []changeList
+	for _, list := range lists {
+		if len(list.Tests) > 0 {
+			nonEmpty = append(nonEmpty, list)
+		}
+	}
+	return nonEmpty
+}
+
+// isolationLines returns the failure classes for the console
+func isolationLines(lists []changeList) []string {
+	counts := make([]string, 0, len(lists))
+	for _, list := range lists {
+		counts = append(counts, fmt.Sprintf("%s: %d", list.Title, len(list.Tests)))
+	}
+	lines := []string{"Failed test cases rerun in isolation: " + strings.Join(counts, ", ")}
+	for _, list := range lists {
+		lines = append(lines, list.Title+":")
+		for _, test := range list.Tests {
+			lines = append(lines, "  "+test)
+		}
+	}
+	return lines
+}
+
+// writeMarkdownIsolation writes the failure classes
+func writeMarkdownIsolation(b *strings.Builder, lists []changeList) {
+	b.WriteString("\n### Failures rerun in isolation\n")
+	for _, list := range lists {
+		fmt.Fprintf(b, "\n**%s (%d)**\n\n", list.Title, len(list.Tests))
+		for i, test := range list.Tests {
+			if i == markdownFailures {
+				fmt.Fprintf(b, "- and %d more\n", len(list.Tests)-markdownFailures)
+				break
+			}
+			fmt.Fprintf(b, "- `%s`\n", test)
+		}
+	}
+}
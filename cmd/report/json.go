@@ -0,0 +1,44 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package report
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/vavuthu/itr/cmd/rundir"
+)
+
+// jsonReporter writes the results document
+type jsonReporter struct{}
+
+func init() {
+	Register("json", jsonReporter{})
+}
+
+func (jsonReporter) DefaultPath() string {
+	return rundir.ResultsFile()
+}
+
+func (jsonReporter) Report(results *Results, path string) error {
+	return write(path, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results.Document)
+	})
+}
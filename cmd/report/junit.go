@@ -0,0 +1,43 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package report
+
+import (
+	"io"
+
+	"github.com/vavuthu/itr/cmd/junit"
+	"github.com/vavuthu/itr/cmd/rundir"
+)
+
+// junitReporter merges the JUnit XML of all test cases and attempts
+type junitReporter struct{}
+
+func init() {
+	Register("junit", junitReporter{})
+}
+
+func (junitReporter) DefaultPath() string {
+	return rundir.JUnitReport()
+}
+
+func (junitReporter) Report(results *Results, path string) error {
+	doc := junit.Merge("itr "+results.Run.ID, results.Tests, results.RunDir)
+	return write(path, func(w io.Writer) error {
+		return junit.Write(w, doc)
+	})
+}
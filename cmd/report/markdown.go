@@ -0,0 +1,167 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package report
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vavuthu/itr/cmd/results"
+	"github.com/vavuthu/itr/cmd/rundir"
+)
+
+const (
+	// GitHubStepSummary as markdown path appends the summary to the step
+	// summary of the GitHub Actions job
+	GitHubStepSummary    = "github"
+	gitHubStepSummaryEnv = "GITHUB_STEP_SUMMARY"
+	// markdownFailures is the number of failures listed, GitHub limits the
+	// size of comments and step summaries
+	markdownFailures     = 50
+	markdownExcerptLines = 20
+)
+
+// markdownReporter writes a compact summary for PR comments, Jira and CI
+// step summaries
+type markdownReporter struct{}
+
+func init() {
+	Register("markdown", markdownReporter{})
+}
+
+func (markdownReporter) DefaultPath() string {
+	return rundir.MarkdownReport()
+}
+
+func (markdownReporter) Report(results *Results, path string) error {
+	summary := Markdown(results)
+	if path != GitHubStepSummary {
+		return write(path, func(w io.Writer) error {
+			_, err := io.WriteString(w, summary)
+			return err
+		})
+	}
+
+	path = os.Getenv(gitHubStepSummaryEnv)
+	if path == "" {
+		return fmt.Errorf("$%s is not set", gitHubStepSummaryEnv)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(summary); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Markdown returns a compact summary of the results for PR comments, Jira and
+// CI step summaries
+func Markdown(r *Results) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "## ITR run %s\n\n", r.Run.ID)
+	verdict := "✅ Passed"
+	for status, count := range r.Summary.Statuses {
+		if status.Failing() && count > 0 {
+			verdict = "❌ Failed"
+		}
+	}
+	fmt.Fprintf(&b, "**%s** · %d test cases in %s · %d attempts (%d retries) · %d flaky\n\n",
+		verdict, r.Summary.Total, formatDuration(time.Duration(r.Run.DurationSeconds*float64(time.Second))),
+		r.Summary.Attempts, r.Summary.Retries, r.Summary.Flaky)
+
+	b.WriteString("| Result | Count |\n|--------|------:|\n")
+	for _, status := range reportStatuses {
+		if count := r.Summary.Statuses[status]; count > 0 {
+			fmt.Fprintf(&b, "| %s | %d |\n", statusLabels[status], count)
+		}
+	}
+	if lists := isolationLists(r.Tests); lists != nil {
+		writeMarkdownIsolation(&b, lists)
+	}
+	if r.Combined != nil {
+		writeMarkdownCombined(&b, r.Combined)
+	}
+	if r.Changes != nil {
+		writeMarkdownChanges(&b, r.Changes)
+	}
+
+	var failures, flaky []results.Test
+	for _, test := range r.Tests {
+		if test.Status.Failing() {
+			failures = append(failures, test)
+		}
+		if test.Flaky {
+			flaky = append(flaky, test)
+		}
+	}
+
+	if len(failures) > 0 {
+		fmt.Fprintf(&b, "\n### Failures (%d)\n\n", len(failures))
+		for i, test := range failures {
+			if i == markdownFailures {
+				fmt.Fprintf(&b, "and %d more, see the HTML report\n", len(failures)-markdownFailures)
+				break
+			}
+			writeFailure(&b, test, r)
+		}
+	}
+
+	if len(flaky) > 0 {
+		fmt.Fprintf(&b, "\n### Flaky (%d)\n\n", len(flaky))
+		for _, test := range flaky {
+			fmt.Fprintf(&b, "- `%s` passed on attempt %d\n", test.ID, len(test.Attempts))
+		}
+	}
+	return b.String()
+}
+
+// writeFailure writes a collapsed failure with the message and the end of its log
+func writeFailure(b *strings.Builder, test results.Test, r *Results) {
+	title := fmt.Sprintf("<code>%s</code> %s", html.EscapeString(test.ID), test.Status)
+	if r.Changes != nil && contains(r.Changes.NewFailures, test.ID) {
+		title = "🆕 " + title
+	}
+	if label, ok := failureClassLabels[test.FailureClass]; ok {
+		title += " (" + label + ")"
+	}
+	if message, _, _ := strings.Cut(test.Message, "\n"); message != "" {
+		title += ": " + html.EscapeString(message)
+	}
+	fmt.Fprintf(b, "<details><summary>%s</summary>\n\n", title)
+
+	if n := len(test.Attempts); n > 0 && test.Attempts[n-1].LogFile != "" {
+		excerpt := logTail(r.Path(test.Attempts[n-1].LogFile), markdownExcerptLines)
+		if excerpt != "" {
+			// a longer fence than any backtick run in the log keeps it intact
+			fence := "```"
+			for strings.Contains(excerpt, fence) {
+				fence += "`"
+			}
+			fmt.Fprintf(b, "%s\n%s\n%s\n\n", fence, excerpt, fence)
+		}
+	}
+	b.WriteString("</details>\n")
+}
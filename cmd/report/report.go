@@ -18,11 +18,12 @@ limitations under the License.
 package report
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
@@ -31,7 +32,10 @@ import (
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 
-	"github.com/vavuthu/itr/cmd/statusquo"
+	"github.com/vavuthu/itr/cmd/payload"
+	"github.com/vavuthu/itr/cmd/results"
+	"github.com/vavuthu/itr/cmd/rundir"
+	"github.com/vavuthu/itr/cmd/runstate"
 	"github.com/vavuthu/itr/cmd/utils"
 	"github.com/vavuthu/itr/config"
 	"github.com/vavuthu/itr/logger"
@@ -45,89 +49,189 @@ const (
 	testReport = "test_report.html"
 )
 
-var (
-	totalTCPassed int
-	totalTCFailed int
-	totalTCSkipped int
-	totalTCNotSelected int
+// resultFiles maps the final statuses to the files listing their test cases
+var resultFiles = map[runstate.Status]string{
+	runstate.Passed:      passed,
+	runstate.XPass:       passed,
+	runstate.Failed:      failed,
+	runstate.Error:       failed,
+	runstate.Skipped:     skipped,
+	runstate.XFail:       skipped,
+	runstate.NotSelected: notSelected,
+}
 
-)
+// finalStatuses are the final statuses of the test cases in report order
+var finalStatuses = []runstate.Status{
+	runstate.Passed, runstate.XPass, runstate.Failed, runstate.Error,
+	runstate.Skipped, runstate.XFail, runstate.NotSelected,
+}
+
+// unfinishedStatuses are the statuses of the test cases a crashed run left
+// behind, reports rebuilt from its event log list them after the final ones
+var unfinishedStatuses = []runstate.Status{
+	runstate.Running, runstate.Retrying, runstate.Queued,
+}
+
+// reportStatuses are all the statuses in report order
+var reportStatuses = append(append([]runstate.Status(nil), finalStatuses...), unfinishedStatuses...)
+
+// statusLabels are the names of the statuses in the reports
+var statusLabels = map[runstate.Status]string{
+	runstate.Passed:      "Passed",
+	runstate.XPass:       "XPass",
+	runstate.Failed:      "Failed",
+	runstate.Error:       "Error",
+	runstate.Skipped:     "Skipped",
+	runstate.XFail:       "XFail",
+	runstate.NotSelected: "NotSelected",
+	runstate.Running:     "Running",
+	runstate.Retrying:    "Retrying",
+	runstate.Queued:      "Queued",
+}
 
-func GenerateSummary(configDir string) {
-	logger.Info("########################### SUMMARY ###########################")
-	
-	passedFilePath := filepath.Join(configDir, passed)
-	failedFilePath := filepath.Join(configDir, failed)
-	skippedFilePath := filepath.Join(configDir, skipped)
-	notSelectedFilepath := filepath.Join(configDir, notSelected)
-
-	// Create a map to hold the different statuses
-	statuses := map[string]string{
-		"Passed":  text.Colors{text.FgGreen}.Sprint("Passed"),
-		"Failed":  text.Colors{text.FgRed}.Sprint("Failed"),
-		"Skipped": text.Colors{text.FgYellow}.Sprint("Skipped"),
-		"NotSelected": text.Colors{text.FgYellow}.Sprint("NotSelected"),
+// statusColors are the console colors of the statuses
+var statusColors = map[runstate.Status]text.Colors{
+	runstate.Passed:      {text.FgGreen},
+	runstate.XPass:       {text.FgGreen},
+	runstate.Failed:      {text.FgRed},
+	runstate.Error:       {text.FgRed},
+	runstate.Skipped:     {text.FgYellow},
+	runstate.XFail:       {text.FgYellow},
+	runstate.NotSelected: {text.FgYellow},
+	runstate.Running:     {text.FgHiBlack},
+	runstate.Retrying:    {text.FgHiBlack},
+	runstate.Queued:      {text.FgHiBlack},
+}
+
+// WriteResultFiles writes the results document and the test cases of each
+// final status to the results directory
+func WriteResultFiles(r *Results) {
+	resultsFile := rundir.ResultsFile()
+	if err := results.Write(resultsFile, r.Document); err != nil {
+		logger.Errorf("Failed to write results %s: %v", resultsFile, err)
+	}
+
+	lines := make(map[string]string)
+	for _, test := range r.Tests {
+		if name, ok := resultFiles[test.Status]; ok {
+			lines[name] += test.ID + "\n"
+		}
 	}
+	for _, name := range []string{passed, failed, skipped, notSelected} {
+		path := filepath.Join(rundir.ResultsDir(), name)
+		if err := os.WriteFile(path, []byte(lines[name]), 0644); err != nil {
+			logger.Errorf("Failed to write %s: %v", path, err)
+		}
+	}
+}
+
+// consoleReporter prints the summary table, colored on the standard output
+type consoleReporter struct{}
+
+func init() {
+	Register("console", consoleReporter{})
+}
+
+func (consoleReporter) DefaultPath() string {
+	return Stdout
+}
+
+func (consoleReporter) Report(results *Results, path string) error {
+	colored := path == Stdout
 
 	// Create a new table
 	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
 
 	// Set column names and widths
-	t.AppendHeader(table.Row{"Test Case", "Status"})
+	t.AppendHeader(table.Row{"Test Case", "Status", "Duration", "Message"})
 	t.SetColumnConfigs([]table.ColumnConfig{
 		{Name: "Test Case", WidthMax: 160},
 		{Name: "Status", WidthMax: 20},
+		{Name: "Message", WidthMax: 80},
 	})
 
-	isPassedFilePathExist := utils.CheckFileExists(passedFilePath)
-	if isPassedFilePathExist {
-		totalTCPassed, _ = utils.CountLines([]string{passedFilePath})
-		processFile(t, passedFilePath, statuses["Passed"])
+	for _, test := range byStatus(results.Tests) {
+		label := statusLabels[test.Status]
+		if colored {
+			label = statusColors[test.Status].Sprint(label)
+		}
+		t.AppendRow(table.Row{test.ID, label, formatDuration(testDuration(test)), testMessage(test)})
+	}
 
+	counts := []string{fmt.Sprintf("Total Test cases: %d", results.Summary.Total)}
+	for _, status := range finalStatuses {
+		counts = append(counts, fmt.Sprintf("%s: %d", statusLabels[status], results.Summary.Statuses[status]))
 	}
-	
-	isFailedFilePathExist := utils.CheckFileExists(failedFilePath)
-	if isFailedFilePathExist {
-		totalTCFailed, _ = utils.CountLines([]string{failedFilePath})
-		processFile(t, failedFilePath, statuses["Failed"])
+	for _, status := range unfinishedStatuses {
+		if count := results.Summary.Statuses[status]; count > 0 {
+			counts = append(counts, fmt.Sprintf("%s: %d", statusLabels[status], count))
+		}
 	}
 
-	isSkippedFilePathExist := utils.CheckFileExists(skippedFilePath)
-	if isSkippedFilePathExist {
-		totalTCSkipped, _ = utils.CountLines([]string{skippedFilePath})
-		processFile(t, skippedFilePath, statuses["Skipped"])
+	if lists := isolationLists(results.Tests); lists != nil {
+		counts = append(counts, isolationLines(lists)...)
 	}
-
-	isNotSelectedFilePathExist := utils.CheckFileExists(notSelectedFilepath)
-	if isNotSelectedFilePathExist {
-		totalTCNotSelected, _ = utils.CountLines([]string{notSelectedFilepath})
-		processFile(t, notSelectedFilepath, statuses["NotSelected"])
+	if results.Combined != nil {
+		counts = append(counts, combinedLines(results.Combined)...)
+	}
+	if results.Changes != nil {
+		counts = append(counts, changeLines(results.Changes)...)
 	}
 
-	logger.Info("Total Test cases: ", statusquo.TotalTestCases)
-	logger.Info("Passed: ", totalTCPassed)
-	logger.Info("Failed: ", totalTCFailed)
-	logger.Info("Skipped: ", totalTCSkipped)
-	logger.Info("NotSelected: ", totalTCNotSelected)
-	logger.Info("###############################################################")
+	if path == Stdout {
+		logger.Info("########################### SUMMARY ###########################")
+		for _, line := range counts {
+			logger.Info(line)
+		}
+		logger.Info("###############################################################")
+		fmt.Println(t.Render())
+		return nil
+	}
+	return write(path, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "%s\n\n%s\n", strings.Join(counts, "\n"), t.Render())
+		return err
+	})
+}
 
-	t.Render()
+// byStatus returns the test cases ordered by final status, followed by the
+// unfinished ones
+func byStatus(tests []results.Test) []results.Test {
+	ordered := make([]results.Test, 0, len(tests))
+	for _, status := range reportStatuses {
+		for _, test := range tests {
+			if test.Status == status {
+				ordered = append(ordered, test)
+			}
+		}
+	}
+	return ordered
 }
 
-// GenerateHTMLReport generates the HTML report
-func GenerateHTMLReport(configDir string, totalTime time.Duration) {
+// testDuration returns the duration of the last attempt of the test case
+func testDuration(test results.Test) time.Duration {
+	return time.Duration(test.FinalDurationSeconds() * float64(time.Second))
+}
 
-	passedFilePath := filepath.Join(configDir, passed)
-	failedFilePath := filepath.Join(configDir, failed)
-	skippedFilePath := filepath.Join(configDir, skipped)
-	notSelectedFilepath := filepath.Join(configDir, notSelected)
+// testMessage returns the first line of the message of the last attempt
+func testMessage(test results.Test) string {
+	message, _, _ := strings.Cut(test.Message, "\n")
+	return message
+}
 
-	passedTests, _ := readLines(passedFilePath)
-	skippedTests, _ := readLines(skippedFilePath)
-	failedTests, _ := readLines(failedFilePath)
-	notSelectedTests, _ := readLines(notSelectedFilepath)
+// formatDuration returns the duration rounded for reports
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	if d < time.Minute {
+		return d.Round(100 * time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
 
+// extractEnvironment returns the environment table of the test framework
+// report in configDir, empty if there is none
+func extractEnvironment(configDir string) map[string]string {
 	envMap := make(map[string]string)
 
 	// check test_report.html exists or not
@@ -193,110 +297,15 @@ func GenerateHTMLReport(configDir string, totalTime time.Duration) {
 		}
 	}
 
-	// Generate HTML content
-	htmlContent := fmt.Sprintf(`
-	<!DOCTYPE html>
-	<html>
-	<head>
-		<title>Test Summary</title>
-	</head>
-	<body>
-    <h1>Summary</h1>
-    <p>%d tests ran in %.2f minutes</p>
-    <p>%d passed, %d skipped, %d failed, %d not selected</p>
-	<h2>Environment</h2>
-	<table border="1" id="environment">
-	`, statusquo.TotalTestCases, totalTime.Minutes(), totalTCPassed, totalTCSkipped, totalTCFailed, totalTCNotSelected)
-
-	for key, value := range envMap {
-		htmlContent += fmt.Sprintf(`
-        <tr>
-            <td>%s</td>
-            <td>%s</td>
-        </tr>
-		`, key, value)
-	}
-
-	htmlContent += `
-    </table>
-	<h2>Results</h2>
-    <table border="1" id="results-table">
-        <tr>
-            <th>Test</th>
-            <th>Result</th>
-        </tr>
-	`
-
-	for _, test := range passedTests {
-		htmlContent += fmt.Sprintf(`
-        <tr>
-            <td>%s</td>
-            <td>Passed</td>
-        </tr>
-		`, test)
-	}
-
-	for _, test := range skippedTests {
-		htmlContent += fmt.Sprintf(`
-        <tr>
-            <td>%s</td>
-            <td>Skipped</td>
-        </tr>
-		`, test)
-	}
-
-	for _, test := range failedTests {
-		htmlContent += fmt.Sprintf(`
-        <tr>
-            <td>%s</td>
-            <td>Failed</td>
-        </tr>
-		`, test)
-	}
-
-	for _, test := range notSelectedTests {
-		htmlContent += fmt.Sprintf(`
-        <tr>
-            <td>%s</td>
-            <td>NotSelected</td>
-        </tr>
-		`, test)
-	}
-
-	htmlContent += `
-    </table>
-	</body>
-	</html>
-	`
-	// Write the HTML content to a file
-	htmlReport := "report_" + config.AppConfig.RunID + ".html"
-	os.WriteFile(htmlReport, []byte(htmlContent), 0644)
-	logger.Infof("HTML file '%s' generated successfully.", htmlReport)
-
+	return envMap
 }
 
-// processFile reads a file and adds rows to the table with the corresponding status color
-func processFile(t table.Writer, filePath string, status string) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		logger.Errorf("Error opening file %s: %s\n", filePath, err)
-		return
-	}
-	defer file.Close()
-
-	// Read file content line by line and add rows to the table
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		testCase := scanner.Text()
-		// Use Colors to set the text color based on status
-		statusString := status
-		t.AppendRow(table.Row{testCase, statusString})
-	}
-
-	// Check for any errors encountered while reading the file
-	if err := scanner.Err(); err != nil {
-		logger.Errorf("Error reading file %s: %s\n", filePath, err)
+// describeContainerOptions returns the container options in podman flag form
+func describeContainerOptions(opts config.ContainerOptions) string {
+	if opts.IsEmpty() {
+		return "default"
 	}
+	return strings.TrimSpace(payload.ContainerArgs(opts))
 }
 
 // unicodeTitle capitalizes the first letter of a string using the Unicode-aware cases package
@@ -304,24 +313,3 @@ func unicodeTitle(s string) string {
 	title := cases.Title(language.Und)
 	return title.String(s)
 }
-
-
-func readLines(filename string) ([]string, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
-	return lines, nil
-}
\ No newline at end of file
@@ -0,0 +1,274 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package report
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/vavuthu/itr/cmd/history"
+	"github.com/vavuthu/itr/cmd/journal"
+	"github.com/vavuthu/itr/cmd/junit"
+	"github.com/vavuthu/itr/cmd/results"
+	"github.com/vavuthu/itr/cmd/rundir"
+	"github.com/vavuthu/itr/cmd/runstate"
+	"github.com/vavuthu/itr/config"
+	"github.com/vavuthu/itr/logger"
+)
+
+// Stdout as report path writes the report to the standard output
+const Stdout = "-"
+
+// Reporter writes the results of a run in one format. Reporters are
+// registered under their format name and selected with --report.
+type Reporter interface {
+	// DefaultPath returns the path the report is written to when
+	// --report gives none
+	DefaultPath() string
+	// Report writes the report of the results to path
+	Report(results *Results, path string) error
+}
+
+// Results is the in-memory results model every reporter consumes
+type Results struct {
+	results.Document
+	// RunDir is the run directory the log and artifact paths of the
+	// attempts are relative to
+	RunDir string
+	// Changes to the previous run of the suite, nil without history
+	Changes *history.Changes
+}
+
+// Path returns the path of a file of the run given relative to the run directory
+func (r *Results) Path(rel string) string {
+	if rel == "" {
+		return ""
+	}
+	return filepath.Join(r.RunDir, filepath.FromSlash(rel))
+}
+
+var (
+	reportersLock sync.RWMutex
+	reporters     = make(map[string]Reporter)
+)
+
+// Register makes a reporter available under a format name, it panics if the
+// name is taken
+func Register(format string, reporter Reporter) {
+	reportersLock.Lock()
+	defer reportersLock.Unlock()
+	if _, ok := reporters[format]; ok {
+		panic("report: reporter registered twice for format " + format)
+	}
+	reporters[format] = reporter
+}
+
+// Lookup returns the reporter of a format
+func Lookup(format string) (Reporter, bool) {
+	reportersLock.RLock()
+	defer reportersLock.RUnlock()
+	reporter, ok := reporters[format]
+	return reporter, ok
+}
+
+// Formats returns the registered format names
+func Formats() []string {
+	reportersLock.RLock()
+	defer reportersLock.RUnlock()
+	formats := make([]string, 0, len(reporters))
+	for format := range reporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Validate checks the reports have a registered format
+func Validate(reports []config.Report) error {
+	for _, r := range reports {
+		if _, ok := Lookup(r.Format); !ok {
+			return fmt.Errorf("unknown report format %q, expected one of %v", r.Format, Formats())
+		}
+	}
+	return nil
+}
+
+// DefaultReports returns the reports generated when --report is not given
+func DefaultReports(junitXML bool) []config.Report {
+	reports := []config.Report{{Format: "console"}, {Format: "html"}, {Format: "markdown"}}
+	if junitXML {
+		reports = append(reports, config.Report{Format: "junit"})
+	}
+	return reports
+}
+
+// Collect returns the results model of the current run
+func Collect(state *runstate.State) *Results {
+	metadata, err := rundir.ReadMetadata()
+	if err != nil {
+		logger.Errorf("Failed to read run metadata: %v", err)
+	}
+	doc := results.Build(metadata, state.Tests())
+	doc.Run.Environment = extractEnvironment(doc.Run.ConfigDir)
+	r := &Results{Document: doc, RunDir: rundir.RunDir()}
+	combine(r)
+	return r
+}
+
+// Load returns the results model of the current run directory from its JSON
+// results, or replayed from its event log when the run ended before writing
+// them. Results missing in the event log are read from the attempts' JUnit
+// XML and logs.
+func Load() (*Results, error) {
+	doc, err := results.Read(rundir.ResultsFile())
+	if err == nil {
+		if doc.Run.Environment == nil {
+			doc.Run.Environment = extractEnvironment(doc.Run.ConfigDir)
+		}
+		r := &Results{Document: doc, RunDir: rundir.RunDir()}
+		if r.Combined == nil {
+			combine(r)
+		}
+		return r, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	records, err := journal.Read(rundir.EventsFile())
+	if len(records) == 0 {
+		return nil, fmt.Errorf("neither results nor events found in %s: %v", rundir.RunDir(), err)
+	}
+	if err != nil {
+		// the last event of a crashed run may be cut off
+		logger.Errorf("Failed to read all events: %v", err)
+	}
+	logger.Infof("No results found in %s, replaying %d events", rundir.RunDir(), len(records))
+
+	metadata, err := rundir.ReadMetadata()
+	if err != nil {
+		logger.Errorf("Failed to read run metadata: %v", err)
+		metadata.RunID = filepath.Base(rundir.RunDir())
+	}
+	if metadata.EndTime.IsZero() {
+		metadata.EndTime = records[len(records)-1].Time
+	}
+
+	tests := journal.Tests(records)
+	for i := range tests {
+		for j := range tests[i].Attempts {
+			attempt := &tests[i].Attempts[j]
+			if attempt.Result != nil || attempt.Status == runstate.Running {
+				continue
+			}
+			if result, ok := junit.AttemptResult(tests[i].ID, attempt.Number, attempt.LogFile); ok {
+				attempt.Result = &result
+			}
+		}
+		if isolation := tests[i].Isolation; isolation != nil && isolation.Result == nil && isolation.Status != runstate.Running {
+			if result, ok := junit.AttemptResult(tests[i].ID, isolation.Number, isolation.LogFile); ok {
+				isolation.Result = &result
+			}
+		}
+	}
+	doc = results.Build(metadata, tests)
+	doc.Run.Environment = extractEnvironment(doc.Run.ConfigDir)
+	r := &Results{Document: doc, RunDir: rundir.RunDir()}
+	combine(r)
+	return r, nil
+}
+
+// CompareWithHistory compares the results with the previous run of the suite
+// in the history database at path and, with record, records the run in it
+func CompareWithHistory(r *Results, path string, record bool) {
+	if _, err := os.Stat(path); err != nil && !record {
+		return
+	}
+	store, err := history.Open(path)
+	if err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		return
+	}
+	defer store.Close()
+
+	previous, err := store.Previous(r.Run)
+	if err != nil {
+		logger.Errorf("Failed to read the previous run of suite %s: %v", r.Run.Suite, err)
+	} else if previous != nil {
+		changes := history.Compare(*previous, r.Document)
+		r.Changes = &changes
+	}
+	if record {
+		if err := store.Record(r.Document); err != nil {
+			logger.Errorf("Failed to record run %s in history %s: %v", r.Run.ID, path, err)
+		}
+	}
+}
+
+// Generate runs the reporters of the reports on the results. A failing
+// reporter doesn't keep the others from running, their errors are returned
+// together.
+func Generate(results *Results, reports []config.Report) error {
+	var errs []error
+	for _, r := range reports {
+		reporter, ok := Lookup(r.Format)
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown report format %q", r.Format))
+			continue
+		}
+		path := r.Path
+		if path == "" {
+			path = reporter.DefaultPath()
+		}
+		if err := reporter.Report(results, path); err != nil {
+			logger.Errorf("Failed to generate %s report %s: %v", r.Format, path, err)
+			errs = append(errs, fmt.Errorf("%s report: %v", r.Format, err))
+			continue
+		}
+		if path != Stdout {
+			logger.Infof("%s report '%s' generated successfully.", r.Format, path)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// write writes the report rendered by render to path, creating its
+// directory, or to the standard output for Stdout
+func write(path string, render func(io.Writer) error) error {
+	if path == Stdout {
+		return render(os.Stdout)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := render(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,173 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vavuthu/itr/cmd/engine"
+	"github.com/vavuthu/itr/cmd/history"
+	"github.com/vavuthu/itr/cmd/report"
+	"github.com/vavuthu/itr/cmd/results"
+	"github.com/vavuthu/itr/cmd/rundir"
+	"github.com/vavuthu/itr/cmd/runstate"
+	"github.com/vavuthu/itr/config"
+	"github.com/vavuthu/itr/logger"
+)
+
+// rerunCmd represents the rerun-failed command
+var rerunCmd = &cobra.Command{
+	Use:   "rerun-failed <run-dir>",
+	Short: "Run the failed test cases of a run again",
+	Long: `Run the failed test cases of a run again with the flags, image and execution file of the run, the disruptive
+ones serially as before. The rerun is a new run next to the original one and linked to it, its reports show the
+combined verdict of both.`,
+	Args: cobra.ExactArgs(1),
+	Run:  rerunRunCmd,
+}
+
+var (
+	rerunFlaky				bool
+	rerunNotRun				bool
+)
+
+// rerunQueues are the queues of the test cases and the flags listing them
+var rerunQueues = []struct {
+	queue string
+	flag  string
+}{
+	{engine.ParallelQueue, "non-disruptive-testcases"},
+	{engine.SerialQueue, "disruptive-testcases"},
+}
+
+func init() {
+	rerunCmd.Flags().BoolVar(&rerunFlaky, "include-flaky", false, "also rerun the test cases that passed on a retry")
+	rerunCmd.Flags().BoolVar(&rerunNotRun, "include-not-run", false, "also rerun the test cases an interrupted run left queued or running")
+	rootCmd.AddCommand(rerunCmd)
+}
+
+func rerunRunCmd(cmd *cobra.Command, args []string) {
+	runDir := filepath.Clean(args[0])
+	config.AppConfig.OutputDir = filepath.Dir(runDir)
+	config.AppConfig.RunID = filepath.Base(runDir)
+
+	original, err := report.Load()
+	if err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+	if _, err := os.Stat(rundir.ResultsFile()); err != nil {
+		// the rerun reads the results of the original run for the combined verdict
+		report.WriteResultFiles(original)
+	}
+	runFlags, err := originalArgs(original.Run)
+	if err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+	if err := rootCmd.ParseFlags(runFlags[1:]); err != nil {
+		logger.Errorf("Failed to parse the arguments of run %s: %v", original.Run.ID, err)
+		os.Exit(1)
+	}
+
+	testCases := rerunTestCases(original.Tests)
+	if len(testCases) == 0 {
+		logger.Infof("Run %s has no test cases to rerun", original.Run.ID)
+		return
+	}
+
+	config.AppConfig.RunID = getRunID()
+	if err := rundir.Create(); err != nil {
+		logger.Errorf("Failed to create run directory %s: %v", rundir.RunDir(), err)
+		os.Exit(1)
+	}
+
+	// later flags take precedence, the recorded arguments rerun the rerun
+	overrides := []string{
+		"--output-dir=" + config.AppConfig.OutputDir,
+		"--suite=" + original.Run.Suite,
+		// a subset of the suite isn't compared with the runs of the whole suite
+		"--history-db=" + history.Disabled,
+	}
+	total := 0
+	for _, q := range rerunQueues {
+		path := ""
+		if tests := testCases[q.queue]; len(tests) > 0 {
+			path = rundir.RerunTestCasesFile(q.queue)
+			if err := os.WriteFile(path, []byte(strings.Join(tests, "\n")+"\n"), 0644); err != nil {
+				logger.Errorf("An error occurred: %v", err)
+				os.Exit(1)
+			}
+			total += len(tests)
+		}
+		overrides = append(overrides, "--"+q.flag+"="+path)
+	}
+	if err := rootCmd.ParseFlags(overrides); err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+	runID = config.AppConfig.RunID
+	runArgs = append(append([]string(nil), original.Run.Args...), overrides...)
+	rerunOf = original.Run.ID
+
+	logger.Infof("Rerunning %d test cases of run %s as run %s", total, original.Run.ID, runID)
+	runCmd(rootCmd, nil)
+}
+
+// originalArgs returns the flags the run was started with, from its unmasked
+// arguments or, for runs of earlier versions, from its metadata
+func originalArgs(run results.Run) ([]string, error) {
+	args, err := rundir.ReadArgs()
+	if err == nil {
+		return args, nil
+	}
+	if len(run.Args) == 0 {
+		return nil, fmt.Errorf("run %s has no recorded arguments to rerun it with", run.ID)
+	}
+	for _, arg := range run.Args {
+		if logger.IsMasked(arg) {
+			return nil, fmt.Errorf("run %s was started with secret values that are masked in its metadata, rerun it with itr and its flags instead", run.ID)
+		}
+	}
+	return run.Args, nil
+}
+
+// rerunTestCases returns the test cases to rerun by queue, in the order of the run
+func rerunTestCases(tests []results.Test) map[string][]string {
+	testCases := make(map[string][]string)
+	for _, test := range tests {
+		if !test.Status.Failing() && !(rerunFlaky && test.Flaky) && !(rerunNotRun && unfinished(test.Status)) {
+			continue
+		}
+		queue := test.Queue
+		if queue != engine.SerialQueue {
+			queue = engine.ParallelQueue
+		}
+		testCases[queue] = append(testCases[queue], test.ID)
+	}
+	return testCases
+}
+
+// unfinished reports whether the test case was left queued or running
+func unfinished(status runstate.Status) bool {
+	return status == runstate.Queued || status == runstate.Running || status == runstate.Retrying
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package results
+
+import (
+	"github.com/vavuthu/itr/cmd/runstate"
+)
+
+// Combined is the final verdict of a rerun together with the runs it reran,
+// the last run of each test case decides its status
+type Combined struct {
+	// RunIDs are the original run first, then its reruns up to this one
+	RunIDs []string `json:"runIds"`
+	Total  int      `json:"total"`
+	// Statuses counts the test cases by combined status
+	Statuses map[runstate.Status]int `json:"statuses"`
+	// PassedOnRerun failed in an earlier run and passed in a rerun
+	PassedOnRerun []string `json:"passedOnRerun"`
+	// Failing failed in the last run of the test case
+	Failing []string `json:"failing"`
+}
+
+// Combine returns the combined verdict of the runs, given from the original
+// run to the last rerun
+func Combine(docs []Document) Combined {
+	combined := Combined{Statuses: make(map[runstate.Status]int)}
+	final := make(map[string]runstate.Status)
+	failedBefore := make(map[string]bool)
+	var order []string
+	for _, doc := range docs {
+		combined.RunIDs = append(combined.RunIDs, doc.Run.ID)
+		for _, test := range doc.Tests {
+			status, ok := final[test.ID]
+			if !ok {
+				order = append(order, test.ID)
+			} else if status.Failing() {
+				failedBefore[test.ID] = true
+			}
+			final[test.ID] = test.Status
+		}
+	}
+
+	combined.Total = len(order)
+	for _, id := range order {
+		status := final[id]
+		combined.Statuses[status]++
+		switch {
+		case status.Failing():
+			combined.Failing = append(combined.Failing, id)
+		case failedBefore[id] && status.Passing():
+			combined.PassedOnRerun = append(combined.PassedOnRerun, id)
+		}
+	}
+	return combined
+}
+
+// Failed reports whether any test case failed for good
+func (c Combined) Failed() bool {
+	return len(c.Failing) > 0
+}
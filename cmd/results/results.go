@@ -0,0 +1,261 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vavuthu/itr/cmd/rundir"
+	"github.com/vavuthu/itr/cmd/runstate"
+)
+
+// SchemaVersion is the version of the results document. Fields may be added
+// within a version, it is increased when a field is removed or changes meaning.
+const SchemaVersion = 1
+
+// Document is the JSON results document of a run
+type Document struct {
+	SchemaVersion int     `json:"schemaVersion"`
+	Run           Run     `json:"run"`
+	Summary       Summary `json:"summary"`
+	Tests         []Test  `json:"tests"`
+	// Combined is the verdict over the runs a rerun reran, nil for other runs
+	Combined *Combined `json:"combined,omitempty"`
+}
+
+// Run is the metadata of the run
+type Run struct {
+	ID              string    `json:"id"`
+	Suite           string    `json:"suite"`
+	Image           string    `json:"image"`
+	Args            []string  `json:"args"`
+	Host            string    `json:"host"`
+	ConfigDir       string    `json:"configDir"`
+	StartTime       time.Time `json:"startTime"`
+	EndTime         time.Time `json:"endTime"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	// Environment is the environment table of the test framework report
+	Environment map[string]string `json:"environment,omitempty"`
+	// RerunOf is the ID of the run this run reran the failed test cases of
+	RerunOf string `json:"rerunOf,omitempty"`
+	// Container holds the container options and environment of the run
+	Container *rundir.Container `json:"container,omitempty"`
+	// TestContainers holds the ones of the test cases set in the manifest
+	TestContainers map[string]rundir.Container `json:"testContainers,omitempty"`
+}
+
+// Summary holds the counts of the run
+type Summary struct {
+	Total int `json:"total"`
+	// Statuses counts the test cases by final status
+	Statuses map[runstate.Status]int `json:"statuses"`
+	Attempts int                     `json:"attempts"`
+	Retries  int                     `json:"retries"`
+	Flaky    int                     `json:"flaky"`
+}
+
+// Test is the record of a test case
+type Test struct {
+	ID    string `json:"id"`
+	Queue string `json:"queue"`
+	// Status is the final status, the framework result of the last attempt
+	// if it reported one
+	Status          runstate.Status `json:"status"`
+	Message         string          `json:"message,omitempty"`
+	DurationSeconds float64         `json:"durationSeconds"`
+	Flaky           bool            `json:"flaky"`
+	Attempts        []Attempt       `json:"attempts"`
+	// Isolation is the rerun on its own of a test case that failed for good
+	// in the parallel queue, with --isolate-failures
+	Isolation *Attempt `json:"isolation,omitempty"`
+	// FailureClass classifies the failure by the isolated rerun
+	FailureClass string `json:"failureClass,omitempty"`
+}
+
+// Attempt is the record of a test case attempt, paths are relative to the
+// run directory
+type Attempt struct {
+	Number          int              `json:"number"`
+	Status          runstate.Status  `json:"status"`
+	Result          *runstate.Result `json:"result,omitempty"`
+	ExitCode        int              `json:"exitCode"`
+	StartTime       time.Time        `json:"startTime"`
+	EndTime         time.Time        `json:"endTime"`
+	DurationSeconds float64          `json:"durationSeconds"`
+	LogFile         string           `json:"logFile"`
+	ArtifactDir     string           `json:"artifactDir"`
+}
+
+// Classes of the failures of test cases rerun in isolation
+const (
+	// FailsOnlyInParallel passed when rerun on its own
+	FailsOnlyInParallel = "parallel_only"
+	// FailsInIsolation failed when rerun on its own too
+	FailsInIsolation = "isolation_too"
+)
+
+// Build returns the results document of the run
+func Build(metadata rundir.Metadata, tests []runstate.Test) Document {
+	doc := Document{
+		SchemaVersion: SchemaVersion,
+		Run: Run{
+			ID:             metadata.RunID,
+			Suite:          metadata.Suite,
+			Image:          metadata.Image,
+			Args:           metadata.Args,
+			Host:           metadata.Host,
+			ConfigDir:      metadata.ConfigDir,
+			StartTime:      metadata.StartTime,
+			EndTime:        metadata.EndTime,
+			RerunOf:        metadata.RerunOf,
+			Container:      metadata.Container,
+			TestContainers: metadata.TestContainers,
+		},
+		Summary: Summary{Total: len(tests), Statuses: make(map[runstate.Status]int)},
+		Tests:   make([]Test, 0, len(tests)),
+	}
+	if !metadata.EndTime.IsZero() {
+		doc.Run.DurationSeconds = metadata.EndTime.Sub(metadata.StartTime).Seconds()
+	}
+
+	for _, test := range tests {
+		record := Test{
+			ID:       test.ID,
+			Queue:    test.Queue,
+			Status:   test.FinalStatus(),
+			Flaky:    test.Flaky(),
+			Attempts: make([]Attempt, 0, len(test.Attempts)),
+		}
+		for _, attempt := range test.Attempts {
+			record.Attempts = append(record.Attempts, newAttempt(attempt))
+			record.DurationSeconds += attempt.Duration
+		}
+		if result := test.FinalResult(); result != nil {
+			record.Message = result.Message
+		}
+		if test.Isolation != nil {
+			isolation := newAttempt(*test.Isolation)
+			record.Isolation = &isolation
+			record.FailureClass = failureClass(isolation)
+		}
+
+		doc.Tests = append(doc.Tests, record)
+		doc.Summary.Statuses[record.Status]++
+		doc.Summary.Attempts += len(record.Attempts)
+		if len(record.Attempts) > 1 {
+			doc.Summary.Retries += len(record.Attempts) - 1
+		}
+		if record.Flaky {
+			doc.Summary.Flaky++
+		}
+	}
+	return doc
+}
+
+func newAttempt(attempt runstate.Attempt) Attempt {
+	return Attempt{
+		Number:          attempt.Number,
+		Status:          attempt.Status,
+		Result:          attempt.Result,
+		ExitCode:        attempt.ExitCode,
+		StartTime:       attempt.StartTime,
+		EndTime:         attempt.EndTime,
+		DurationSeconds: attempt.Duration,
+		LogFile:         relative(attempt.LogFile),
+		ArtifactDir:     relative(attempt.ArtifactDir),
+	}
+}
+
+// failureClass classifies a failure by its isolated rerun, it is empty while
+// the rerun runs or when it neither passed nor failed, e.g. was skipped
+func failureClass(isolation Attempt) string {
+	status := isolation.Status
+	if isolation.Result != nil {
+		status = isolation.Result.Status
+	}
+	switch status {
+	case runstate.Passed, runstate.XPass:
+		return FailsOnlyInParallel
+	case runstate.Failed, runstate.Error, runstate.Hung:
+		return FailsInIsolation
+	}
+	return ""
+}
+
+// FinalDurationSeconds returns the duration of the last attempt of the test
+// case, as reported by the test framework if it did
+func (t Test) FinalDurationSeconds() float64 {
+	n := len(t.Attempts)
+	if n == 0 {
+		return 0
+	}
+	attempt := t.Attempts[n-1]
+	if attempt.Result != nil && attempt.Result.Duration > 0 {
+		return attempt.Result.Duration
+	}
+	return attempt.DurationSeconds
+}
+
+// Write writes the results document to path
+func Write(path string, doc Document) error {
+	content, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// Read reads the results document at path
+func Read(path string) (Document, error) {
+	var doc Document
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return doc, err
+	}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return doc, fmt.Errorf("failed to parse results %s: %v", path, err)
+	}
+	if doc.SchemaVersion > SchemaVersion {
+		return doc, fmt.Errorf("results %s have schema version %d, this ITR reads up to %d", path, doc.SchemaVersion, SchemaVersion)
+	}
+	return doc, nil
+}
+
+// relative returns the path relative to the run directory
+func relative(path string) string {
+	if path == "" {
+		return ""
+	}
+	runDir, err := filepath.Abs(rundir.RunDir())
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(runDir, abs)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
@@ -17,12 +17,28 @@ limitations under the License.
 package cmd
 
 import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
+	"github.com/vavuthu/itr/cmd/cleanup"
 	"github.com/vavuthu/itr/cmd/engine"
+	"github.com/vavuthu/itr/cmd/history"
+	"github.com/vavuthu/itr/cmd/journal"
+	"github.com/vavuthu/itr/cmd/report"
+	"github.com/vavuthu/itr/cmd/rundir"
+	"github.com/vavuthu/itr/cmd/runstate"
+	"github.com/vavuthu/itr/cmd/server"
+	"github.com/vavuthu/itr/cmd/tracing"
+	"github.com/vavuthu/itr/cmd/tui"
 	"github.com/vavuthu/itr/cmd/validate"
 	"github.com/vavuthu/itr/config"
 	"github.com/vavuthu/itr/logger"
@@ -35,20 +51,51 @@ var rootCmd = &cobra.Command{
 	Long:  `Intelligent Test Runner (ITR) is tool that runs the test cases in parallel with user controlled queues.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
+	PreRun: validateFlags,
 	Run: runCmd,
 }
 
 var (
 	configDir				string
+	containerArgs				[]string
+	cpus					string
 	disruptiveTestCases			string
 	email					string
+	envFiles				[]string
+	envPairs				[]string
+	envPassthrough				[]string
 	executionFile 				string
+	hangPolicy				string
+	hangTimeout				time.Duration
+	historyDB				string
+	hookPairs				[]string
 	image 					string
+	isolateFailures				bool
 	junitXML 				bool
+	manifestFile				string
+	memory					string
+	network					string
 	nonDisruptiveTestCases 			string
+	otlpEndpoint				string
+	outputDir				string
+	pidsLimit				int
 	queueLength 				int
+	reportPairs				[]string
 	retry 					int
+	secretEnv				[]string
+	statusAddr				string
+	tuiMode					bool
 	subject			    		string
+	suite					string
+	traceFile				string
+	volumes					[]string
+)
+
+// Set by rerun-failed, which starts the run itself
+var (
+	runID					string   // ID of the run instead of a new one, its run directory exists
+	runArgs					[]string // arguments recorded in the metadata instead of the command line
+	rerunOf					string   // ID of the run the failed test cases are rerun of
 )
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -73,27 +120,124 @@ func init() {
 	rootCmd.Flags().StringVarP(&executionFile, "execution", "e", "", "how to execute the test cases")
 	rootCmd.Flags().StringVarP(&image, "image", "i", "", "image name of test framework that should exist in system")
 	rootCmd.Flags().StringVarP(&configDir, "config-dir", "c", "", "path to external configuration files that are passed to test framework")
+	rootCmd.Flags().StringVarP(&outputDir, "output-dir", "o", rundir.DefaultOutputDir, "directory where the output of each run is stored under its run ID")
 	rootCmd.Flags().IntVarP(&queueLength, "queue-length", "q", 5, "Queue length, number of test cases to run parallelly")
 	rootCmd.Flags().IntVarP(&retry, "retry", "r", 0, "number of times to retry the failed test cases")
 	rootCmd.Flags().StringVarP(&email, "email", "m", "", "email to send reports")
 	rootCmd.Flags().StringVarP(&subject, "subject", "s", "", "email subject")
+	rootCmd.Flags().StringVarP(&manifestFile, "manifest", "f", "", "path to manifest file with run level and per test case settings")
+	rootCmd.Flags().StringVar(&cpus, "cpus", "", "number of CPUs for each test container")
+	rootCmd.Flags().StringVar(&memory, "memory", "", "memory limit for each test container (e.g. 4g)")
+	rootCmd.Flags().IntVar(&pidsLimit, "pids-limit", 0, "pids limit for each test container")
+	rootCmd.Flags().StringVar(&network, "network", "", "network mode for each test container")
+	rootCmd.Flags().StringArrayVar(&volumes, "volume", nil, "extra volume for each test container in source:target[:options] form, options are ro, rw, z and Z")
+	rootCmd.Flags().StringArrayVar(&containerArgs, "container-arg", nil, "extra argument passed to podman run for each test container")
+	rootCmd.Flags().StringArrayVar(&envPairs, "env", nil, "environment variable in KEY=VALUE form set in each test container")
+	rootCmd.Flags().StringArrayVar(&envFiles, "env-file", nil, "file of KEY=VALUE lines set in each test container")
+	rootCmd.Flags().StringArrayVar(&envPassthrough, "env-passthrough", nil, "environment variable passed from ITR to each test container, a trailing * passes every variable with that prefix")
+	rootCmd.Flags().StringArrayVar(&secretEnv, "secret-env", nil, "environment variable whose value is masked in logs and reports")
+	rootCmd.Flags().DurationVar(&hangTimeout, "hang-timeout", 0, "time without output after which a test case is considered hung (e.g. 30m), disabled if not given")
+	rootCmd.Flags().StringVar(&hangPolicy, "hang-policy", config.HangWarn, "what to do with a hung test case: warn, dump (run the hang hooks) or kill (run the hang hooks and retry it)")
+	rootCmd.Flags().StringArrayVar(&hookPairs, "hook", nil, "command run on a hook event in EVENT=COMMAND form, the only event is hang")
+	rootCmd.Flags().StringArrayVar(&reportPairs, "report", nil, "report generated at the end of the run in FORMAT[=PATH] form, formats are console, html, json, junit, markdown and csv, - as path writes to stdout and github appends the Markdown summary to $GITHUB_STEP_SUMMARY (default console, html, markdown and junit with -j)")
+	rootCmd.Flags().StringVar(&suite, "suite", "", "name the run is compared with earlier runs under in the history (default the names of the test case files)")
+	rootCmd.Flags().StringVar(&historyDB, "history-db", "", "history database runs are recorded in and compared with (default history.db in the output dir), none disables it")
+	rootCmd.Flags().BoolVar(&isolateFailures, "isolate-failures", false, "after the parallel queue, rerun the test cases that failed for good one at a time to tell the ones failing only under parallelism")
+	rootCmd.Flags().StringVar(&statusAddr, "status-addr", "", "address (e.g. :8080) to serve the live dashboard, status API and metrics on, disabled if not given")
+	rootCmd.Flags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP/HTTP endpoint (e.g. http://tempo:4318) the trace of the run is exported to")
+	rootCmd.Flags().StringVar(&traceFile, "trace-file", "", "file the trace of the run is written to as JSON, for offline use")
+	rootCmd.Flags().BoolVar(&tuiMode, "tui", false, "show an interactive progress UI instead of the console log, ignored when stdout is not a terminal")
 	rootCmd.PersistentFlags().BoolVarP(&junitXML, "junit-xml", "j", false, "Generate JUnit XML report")
 	rootCmd.MarkFlagRequired("image")
-	cobra.OnInitialize(validateFlags)
 
 }
 
 func runCmd(cmd *cobra.Command, args []string) {
-	logger.Infof("Queue length: %d", queueLength)
 	config.InitializeConfig(getRetry(), getEmail(), getRunID(), getConfigDir(), getSubject(), nil)
-	if len(disruptiveTestCases) != 0 {
+	config.AppConfig.OutputDir = outputDir
+	config.AppConfig.JUnitXML = junitXML
+	config.AppConfig.IsolateFailures = isolateFailures
+	// rerun-failed creates the run directory of the run ID it gives
+	if runID == "" {
+		if err := rundir.Create(); err != nil {
+			logger.Errorf("Failed to create run directory %s: %v", rundir.RunDir(), err)
+			os.Exit(1)
+		}
+	}
+	if err := logger.SetLogFile(rundir.ITRLogFile()); err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+	logger.Infof("Run directory: %s", rundir.RunDir())
+	logger.Infof("Queue length: %d", queueLength)
+	manifest, err := config.LoadManifest(manifestFile)
+	if err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+	containerOptions := manifest.Container.Merge(getContainerOptions())
+	if err := containerOptions.Validate(); err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+	envOptions, err := getEnvOptions(manifest)
+	if err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+	secretNames := registerSecrets(envOptions, manifest)
+	hangOptions := config.HangOptions{Timeout: hangTimeout, Policy: hangPolicy}
+	if err := hangOptions.Validate(); err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+	hooks, err := config.ParseHooks(hookPairs)
+	if err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+	reports, err := getReports()
+	if err != nil {
+		logger.Errorf("An error occurred: %v", err)
+		os.Exit(1)
+	}
+	config.AppConfig.Manifest = manifest
+	config.AppConfig.Reports = reports
+	config.AppConfig.HistoryDB = getHistoryDB()
+	config.AppConfig.Container = containerOptions
+	config.AppConfig.ContainerEnv = envOptions
+	config.AppConfig.Hang = hangOptions
+	config.AppConfig.Hooks = manifest.Hooks.Merge(hooks)
+	metadata := getMetadata()
+	metadata.Secrets = secretNames
+	metadata.Container, metadata.TestContainers = getContainers(containerOptions, envOptions, manifest)
+	if err := rundir.WriteMetadata(metadata); err != nil {
+		logger.Errorf("Failed to write run metadata: %v", err)
+	}
+	if err := rundir.WriteArgs(getRunArgs(cmd)); err != nil {
+		logger.Errorf("Failed to write run arguments: %v", err)
+	}
+	if err := journal.Start(runstate.Current, rundir.EventsFile()); err != nil {
+		logger.Errorf("An error occurred: %v", err)
+	}
+	if err := tracing.Start(runstate.Current, tracing.Options{Endpoint: otlpEndpoint, File: traceFile, Cluster: getCluster()}); err != nil {
+		logger.Errorf("An error occurred: %v", err)
+	}
+	cleanup.WarnOrphans(config.AppConfig.RunID)
+	if statusAddr != "" {
+		server.Start(statusAddr, server.NewMux())
+	}
+	if tuiMode {
+		tui.Start(runstate.Current)
+	}
+	if len(disruptiveTestCases) != 0 || isolateFailures {
 		config.UpdateConfigEnv("isSerialEngineNeeded", true)
 	}
 	engine.RunEngine(executionFile, configDir, nonDisruptiveTestCases, disruptiveTestCases, image, queueLength, retry, junitXML)
 }
 
-func validateFlags() {
-	err := validate.Flags(rootCmd, nil)
+func validateFlags(cmd *cobra.Command, args []string) {
+	err := validate.Flags(cmd, args)
 	if err != nil {
 		logger.Errorf("An error occurred: %v", err)
 		os.Exit(1)
@@ -104,6 +248,249 @@ func getConfigDir() string {
 	return configDir
 }
 
+// getCluster returns the name of the cluster the tests run against, the
+// config dir is named after it
+func getCluster() string {
+	if configDir == "" {
+		return ""
+	}
+	return filepath.Base(filepath.Clean(configDir))
+}
+
+// getReports returns the reports given with --report or the default ones
+func getReports() ([]config.Report, error) {
+	reports, err := config.ParseReports(reportPairs)
+	if err != nil {
+		return nil, err
+	}
+	if err := report.Validate(reports); err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		reports = report.DefaultReports(junitXML)
+	}
+	if email == "" {
+		return reports, nil
+	}
+	return addHTMLReport(reports), nil
+}
+
+// addHTMLReport adds the HTML report at its default path, the body of the
+// email, unless it is already there
+func addHTMLReport(reports []config.Report) []config.Report {
+	for _, r := range reports {
+		if r.Format == "html" && r.Path == "" {
+			return reports
+		}
+	}
+	return append(reports, config.Report{Format: "html"})
+}
+
+// getContainerOptions returns the container options given as flags
+func getContainerOptions() config.ContainerOptions {
+	return config.ContainerOptions{
+		CPUs:      cpus,
+		Memory:    memory,
+		PidsLimit: pidsLimit,
+		Network:   network,
+		Volumes:   volumes,
+		ExtraArgs: containerArgs,
+	}
+}
+
+// getEnvOptions returns the container environment from the manifest, env files
+// and flags, later ones taking precedence
+func getEnvOptions(manifest *config.Manifest) (config.EnvOptions, error) {
+	envOptions := config.EnvOptions{
+		Env: make(map[string]string),
+	}
+	envOptions.Passthrough = append(envOptions.Passthrough, config.DefaultEnvPassthrough...)
+	envOptions.Passthrough = append(envOptions.Passthrough, manifest.EnvPassthrough...)
+	envOptions.Passthrough = append(envOptions.Passthrough, envPassthrough...)
+	envOptions.Secrets = append(envOptions.Secrets, manifest.SecretEnv...)
+	envOptions.Secrets = append(envOptions.Secrets, secretEnv...)
+
+	for k, v := range manifest.Env {
+		envOptions.Env[k] = v
+	}
+	for _, envFile := range envFiles {
+		env, err := config.ParseEnvFile(envFile)
+		if err != nil {
+			return envOptions, err
+		}
+		for k, v := range env {
+			envOptions.Env[k] = v
+		}
+	}
+	env, err := config.ParseEnvPairs(envPairs)
+	if err != nil {
+		return envOptions, err
+	}
+	for k, v := range env {
+		envOptions.Env[k] = v
+	}
+
+	return envOptions, nil
+}
+
+// registerSecrets masks the values of secret variables in the logs and
+// returns the names of the variables it masked
+func registerSecrets(envOptions config.EnvOptions, manifest *config.Manifest) []string {
+	names := make(map[string]bool)
+	for k, v := range envOptions.Env {
+		if envOptions.IsSecret(k) {
+			logger.AddSecret(v)
+			names[k] = true
+		}
+	}
+	for _, testConfig := range manifest.Tests {
+		for k, v := range testConfig.Env {
+			if envOptions.IsSecret(k) {
+				logger.AddSecret(v)
+				names[k] = true
+			}
+		}
+	}
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		if envOptions.MatchesPassthrough(k) && envOptions.IsSecret(k) {
+			logger.AddSecret(v)
+			names[k] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// getContainers returns the container options and environment of the run and
+// of the test cases set in the manifest, with the secret values masked
+func getContainers(containerOptions config.ContainerOptions, envOptions config.EnvOptions, manifest *config.Manifest) (*rundir.Container, map[string]rundir.Container) {
+	run := &rundir.Container{
+		Options:     containerOptions,
+		Passthrough: envOptions.Passthrough,
+		Env:         maskEnv(envOptions, envOptions.Env),
+	}
+	var tests map[string]rundir.Container
+	for testCase, testConfig := range manifest.Tests {
+		if tests == nil {
+			tests = make(map[string]rundir.Container)
+		}
+		tests[testCase] = rundir.Container{Options: testConfig.Container, Env: maskEnv(envOptions, testConfig.Env)}
+	}
+	return run, tests
+}
+
+// maskEnv returns the variables with the values of secret variables masked
+func maskEnv(envOptions config.EnvOptions, env map[string]string) map[string]string {
+	masked := make(map[string]string, len(env))
+	for k, v := range env {
+		if envOptions.IsSecret(k) {
+			v = "*****"
+		}
+		masked[k] = logger.Mask(v)
+	}
+	return masked
+}
+
+// getMetadata returns the metadata of the run being started
+func getMetadata() rundir.Metadata {
+	host, _ := os.Hostname()
+	cmdline := os.Args
+	if runArgs != nil {
+		cmdline = runArgs
+	}
+	args := make([]string, 0, len(cmdline))
+	for _, arg := range cmdline {
+		args = append(args, logger.Mask(arg))
+	}
+	return rundir.Metadata{
+		RunID:     config.AppConfig.RunID,
+		Suite:     getSuite(),
+		Image:     image,
+		Args:      args,
+		Host:      host,
+		ConfigDir: configDir,
+		StartTime: time.Now(),
+		RerunOf:   rerunOf,
+	}
+}
+
+// pathFlags are the flags taking a path, recorded as absolute paths so the
+// run can be rerun from another directory
+var pathFlags = map[string]bool{
+	"non-disruptive-testcases": true,
+	"disruptive-testcases":     true,
+	"execution":                true,
+	"config-dir":               true,
+	"output-dir":               true,
+	"manifest":                 true,
+	"env-file":                 true,
+	"trace-file":               true,
+	"history-db":               true,
+}
+
+// getRunArgs returns the flags set for the run for rerun-failed, with
+// absolute paths and unmasked values
+func getRunArgs(cmd *cobra.Command) []string {
+	args := []string{os.Args[0]}
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		values := []string{flag.Value.String()}
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			values = slice.GetSlice()
+		}
+		for _, value := range values {
+			if pathFlags[flag.Name] && value != "" && value != history.Disabled {
+				if abs, err := filepath.Abs(value); err == nil {
+					value = abs
+				}
+			}
+			if flag.Name == "report" {
+				value = absReportPath(value)
+			}
+			args = append(args, "--"+flag.Name+"="+value)
+		}
+	})
+	return args
+}
+
+// absReportPath returns the report in FORMAT[=PATH] form with an absolute path
+func absReportPath(value string) string {
+	format, path, ok := strings.Cut(value, "=")
+	if !ok || path == report.Stdout || path == report.GitHubStepSummary {
+		return value
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return format + "=" + path
+}
+
+// getSuite returns the suite of the run, by default named after the test case files
+func getSuite() string {
+	if suite != "" {
+		return suite
+	}
+	var names []string
+	for _, file := range []string{nonDisruptiveTestCases, disruptiveTestCases} {
+		if file != "" {
+			names = append(names, filepath.Base(file))
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+// getHistoryDB returns the path of the history database, empty if it is disabled
+func getHistoryDB() string {
+	if historyDB == history.Disabled {
+		return ""
+	}
+	return history.Path(historyDB, outputDir)
+}
+
 func getEmail() string {
 	return email
 }
@@ -112,10 +499,18 @@ func getRetry() int {
 	return retry
 }
 
+// getRunID returns the ID of the run, its start time and a random suffix so
+// runs started in the same second get their own run directory
 func getRunID() string {
-	currentTime := time.Now()
-	timestamp := currentTime.Format("2019-02-09_17-50-01")
-	return timestamp
+	if runID != "" {
+		return runID
+	}
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		// fall back on the nanoseconds, still distinct for runs of the same second
+		binary.BigEndian.PutUint16(suffix, uint16(time.Now().Nanosecond()))
+	}
+	return time.Now().Format("2006-01-02_15-04-05") + "-" + hex.EncodeToString(suffix)
 }
 
 func getSubject() string {
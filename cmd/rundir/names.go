@@ -0,0 +1,114 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package rundir
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+const (
+	// maxFileNameLength keeps the file names well below the file system limit
+	maxFileNameLength = 120
+	logIndexFile = "index.json"
+)
+
+var (
+	invalidFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+	logIndexLock sync.Mutex
+)
+
+// FileName returns the file name of a test case. The full node ID is
+// sanitized, truncated when too long and suffixed with its hash, so test
+// cases differing only in the class or in characters that are not allowed
+// in file names don't collide.
+func FileName(testCase string) string {
+	sum := sha256.Sum256([]byte(testCase))
+	hash := hex.EncodeToString(sum[:4])
+
+	name := invalidFileNameChars.ReplaceAllString(testCase, "_")
+	if len(name) > maxFileNameLength-len(hash)-1 {
+		name = name[:maxFileNameLength-len(hash)-1]
+	}
+	return name + "-" + hash
+}
+
+// LogFile returns the log file of a test case attempt
+func LogFile(testCase string, attempt int) string {
+	return filepath.Join(LogsDir(), FileName(testCase)+"."+strconv.Itoa(attempt)+".log")
+}
+
+// JUnitFile returns the JUnit XML file written by a test case attempt with -j
+func JUnitFile(testCase string, attempt int) string {
+	return filepath.Join(ArtifactDir(testCase, attempt), JUnitFileName(testCase))
+}
+
+// JUnitFileName returns the name of the JUnit XML file in the artifact
+// directory of a test case attempt
+func JUnitFileName(testCase string) string {
+	return FileName(testCase) + ".xml"
+}
+
+// LogIndexFile returns the path of the index mapping test case IDs to their log files
+func LogIndexFile() string {
+	return filepath.Join(LogsDir(), logIndexFile)
+}
+
+// ReadLogIndex returns the log files of every test case, in attempt order
+// and relative to the run directory
+func ReadLogIndex() (map[string][]string, error) {
+	index := make(map[string][]string)
+	content, err := os.ReadFile(LogIndexFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return index, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(content, &index)
+	return index, err
+}
+
+// AddLogToIndex records the log file of a test case attempt in the index
+func AddLogToIndex(testCase, logFile string) error {
+	logIndexLock.Lock()
+	defer logIndexLock.Unlock()
+
+	index, err := ReadLogIndex()
+	if err != nil {
+		return err
+	}
+
+	relPath, err := filepath.Rel(RunDir(), logFile)
+	if err != nil {
+		relPath = logFile
+	}
+	index[testCase] = append(index[testCase], filepath.ToSlash(relPath))
+
+	content, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(LogIndexFile(), content, 0644)
+}
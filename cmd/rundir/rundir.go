@@ -0,0 +1,249 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package rundir
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/vavuthu/itr/config"
+)
+
+// Layout of the run directory <output dir>/<run id>
+const (
+	logsDir = "logs"
+	resultsDir = "results"
+	reportsDir = "reports"
+	journalDir = "journal"
+	artifactsDir = "artifacts"
+	metadataFile = "metadata.json"
+	// argsFile holds the flags of the run unmasked, only for its owner
+	argsFile = "args.json"
+	itrLogFile = "itr.log"
+	htmlReportFile = "report.html"
+	junitReportFile = "junit.xml"
+	resultsFile = "results.json"
+	markdownReportFile = "summary.md"
+	csvReportFile = "results.csv"
+	eventsFile = "events.ndjson"
+	// rerunFilePrefix names the test case lists of a rerun, one per queue
+	rerunFilePrefix = "rerun_"
+	// testIDFile holds the full test case ID in each test directory
+	testIDFile = "test_id"
+	// DefaultOutputDir is used when --output-dir is not given
+	DefaultOutputDir = "runs"
+)
+
+// Metadata describes the run, it is written to metadata.json in the run directory
+type Metadata struct {
+	RunID     string    `json:"runId"`
+	// Suite groups the runs of the same test cases in the history
+	Suite     string    `json:"suite"`
+	Image     string    `json:"image"`
+	Args      []string  `json:"args"`
+	Host      string    `json:"host"`
+	ConfigDir string    `json:"configDir"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime,omitempty"`
+	// RerunOf is the ID of the run this run reran the failed test cases of
+	RerunOf   string    `json:"rerunOf,omitempty"`
+	// Secrets names the variables whose values were masked, never the values
+	Secrets   []string  `json:"secrets,omitempty"`
+	// Container holds the container options and environment of the run
+	Container *Container `json:"container,omitempty"`
+	// TestContainers holds the ones of the test cases set in the manifest
+	TestContainers map[string]Container `json:"testContainers,omitempty"`
+}
+
+// Container records the container options and environment of the run or of a
+// test case, with the values of secret variables masked
+type Container struct {
+	Options     config.ContainerOptions `json:"options"`
+	Passthrough []string                `json:"passthrough,omitempty"`
+	Env         map[string]string       `json:"env,omitempty"`
+}
+
+// RunDir returns the directory holding the output of the current run
+func RunDir() string {
+	outputDir := config.AppConfig.OutputDir
+	if outputDir == "" {
+		outputDir = DefaultOutputDir
+	}
+	return filepath.Join(outputDir, config.AppConfig.RunID)
+}
+
+// LogsDir returns the directory holding the ITR log and the test case logs
+func LogsDir() string {
+	return filepath.Join(RunDir(), logsDir)
+}
+
+// ResultsDir returns the directory holding the test case result lists
+func ResultsDir() string {
+	return filepath.Join(RunDir(), resultsDir)
+}
+
+// ReportsDir returns the directory holding the generated reports
+func ReportsDir() string {
+	return filepath.Join(RunDir(), reportsDir)
+}
+
+// JournalDir returns the directory holding the machine readable record of the run
+func JournalDir() string {
+	return filepath.Join(RunDir(), journalDir)
+}
+
+// ITRLogFile returns the path of the ITR log
+func ITRLogFile() string {
+	return filepath.Join(LogsDir(), itrLogFile)
+}
+
+// HTMLReport returns the path of the HTML report
+func HTMLReport() string {
+	return filepath.Join(ReportsDir(), htmlReportFile)
+}
+
+// ResultsFile returns the path of the JSON results document of the run
+func ResultsFile() string {
+	return ResultsFileOf(RunDir())
+}
+
+// ResultsFileOf returns the path of the JSON results document in a run directory
+func ResultsFileOf(runDir string) string {
+	return filepath.Join(runDir, resultsDir, resultsFile)
+}
+
+// RerunTestCasesFile returns the path of the list of test cases a rerun runs in a queue
+func RerunTestCasesFile(queue string) string {
+	return filepath.Join(RunDir(), rerunFilePrefix+queue+".txt")
+}
+
+// MarkdownReport returns the path of the Markdown summary of the run
+func MarkdownReport() string {
+	return filepath.Join(ReportsDir(), markdownReportFile)
+}
+
+// CSVReport returns the path of the CSV report of the run
+func CSVReport() string {
+	return filepath.Join(ReportsDir(), csvReportFile)
+}
+
+// JUnitReport returns the path of the JUnit XML report merged from all test cases
+func JUnitReport() string {
+	return filepath.Join(ReportsDir(), junitReportFile)
+}
+
+// EventsFile returns the path of the event log of the run
+func EventsFile() string {
+	return filepath.Join(JournalDir(), eventsFile)
+}
+
+// MetadataFile returns the path of the run metadata
+func MetadataFile() string {
+	return filepath.Join(RunDir(), metadataFile)
+}
+
+// Create creates the run directory layout, it fails if the run directory
+// exists so runs never share one
+func Create() error {
+	if err := os.MkdirAll(filepath.Dir(RunDir()), 0755); err != nil {
+		return err
+	}
+	if err := os.Mkdir(RunDir(), 0755); err != nil {
+		return err
+	}
+	for _, dir := range []string{LogsDir(), ResultsDir(), ReportsDir(), JournalDir(), filepath.Join(RunDir(), artifactsDir)} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteMetadata writes the run metadata to the run directory
+func WriteMetadata(metadata Metadata) error {
+	content, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(MetadataFile(), content, 0644)
+}
+
+// ReadMetadata reads the run metadata from the run directory
+func ReadMetadata() (Metadata, error) {
+	var metadata Metadata
+	content, err := os.ReadFile(MetadataFile())
+	if err != nil {
+		return metadata, err
+	}
+	err = json.Unmarshal(content, &metadata)
+	return metadata, err
+}
+
+// WriteArgs writes the flags to rerun the run with, they may hold secrets so
+// only the owner can read them
+func WriteArgs(args []string) error {
+	content, err := json.MarshalIndent(args, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(RunDir(), argsFile), content, 0600)
+}
+
+// ReadArgs reads the flags to rerun the run with
+func ReadArgs() ([]string, error) {
+	var args []string
+	content, err := os.ReadFile(filepath.Join(RunDir(), argsFile))
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(content, &args)
+	return args, err
+}
+
+// TestDir returns the directory holding the attempts of a test case
+func TestDir(testCase string) string {
+	return filepath.Join(RunDir(), artifactsDir, FileName(testCase))
+}
+
+// ArtifactDir returns the artifact directory of a test case attempt
+func ArtifactDir(testCase string, attempt int) string {
+	return filepath.Join(TestDir(testCase), strconv.Itoa(attempt))
+}
+
+// CreateArtifactDir creates the artifact directory of a test case attempt
+// and returns its absolute path
+func CreateArtifactDir(testCase string, attempt int) (string, error) {
+	dir, err := filepath.Abs(ArtifactDir(testCase, attempt))
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	// containers may run as a different user than ITR
+	os.Chmod(dir, 0777)
+
+	idFile := filepath.Join(TestDir(testCase), testIDFile)
+	if err := os.WriteFile(idFile, []byte(testCase+"\n"), 0644); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
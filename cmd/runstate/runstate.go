@@ -0,0 +1,530 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package runstate
+
+import (
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	Queued      Status = "queued"
+	Running     Status = "running"
+	Retrying    Status = "retrying"
+	Passed      Status = "passed"
+	Failed      Status = "failed"
+	NotSelected Status = "not_selected"
+	// Hung is the status of an attempt killed for not writing any output
+	Hung        Status = "hung"
+)
+
+// Results reported by the test framework for an attempt, in addition to
+// Passed and Failed
+const (
+	Error   Status = "error"
+	Skipped Status = "skipped"
+	XFail   Status = "xfail"
+	XPass   Status = "xpass"
+)
+
+// Failing reports whether a final status counts as failed
+func (s Status) Failing() bool {
+	return s == Failed || s == Error
+}
+
+// Passing reports whether a final status counts as passed
+func (s Status) Passing() bool {
+	return s == Passed || s == XPass
+}
+
+// subscriberBuffer is the number of events kept for a slow subscriber,
+// further events are dropped for it instead of blocking the launcher
+const subscriberBuffer = 256
+
+// Attempt is a single execution of a test case
+type Attempt struct {
+	Number      int       `json:"number"`
+	Status      Status    `json:"status"`
+	ExitCode    int       `json:"exitCode"`
+	StartTime   time.Time `json:"startTime"`
+	EndTime     time.Time `json:"endTime,omitempty"`
+	Duration    float64   `json:"durationSeconds"`
+	LogFile     string    `json:"logFile"`
+	ArtifactDir string    `json:"artifactDir"`
+	// Result is reported by the test framework, nil if it reported nothing
+	Result      *Result   `json:"result,omitempty"`
+}
+
+// Result is the outcome of an attempt reported by the test framework
+type Result struct {
+	Status   Status  `json:"status"`
+	Message  string  `json:"message,omitempty"`
+	Duration float64 `json:"durationSeconds"`
+}
+
+// Test is the state of a test case
+type Test struct {
+	ID       string    `json:"id"`
+	Queue    string    `json:"queue"`
+	Status   Status    `json:"status"`
+	Attempts []Attempt `json:"attempts"`
+	// StalledSince is the time of the last output of a running test case
+	// that stopped writing output, zero if it is not stalled
+	StalledSince time.Time `json:"stalledSince,omitempty"`
+	// Isolation is the rerun on its own of a test case that failed for good
+	// in the parallel queue, it doesn't count as an attempt
+	Isolation *Attempt `json:"isolation,omitempty"`
+}
+
+// FinalStatus returns the result of the last attempt reported by the test
+// framework, or the status of the test case if there is none. A result
+// reported before the attempt hung, was killed or crashed doesn't make it
+// pass, only a failure or error of the framework is more specific then.
+func (t Test) FinalStatus() Status {
+	n := len(t.Attempts)
+	if n == 0 || t.Attempts[n-1].Result == nil || t.Status == Running || t.Status == Retrying {
+		return t.Status
+	}
+	attempt := t.Attempts[n-1]
+	if (attempt.Status == Failed || attempt.Status == Hung) && !attempt.Result.Status.Failing() {
+		return t.Status
+	}
+	return attempt.Result.Status
+}
+
+// Flaky reports whether the test case passed after failed attempts
+func (t Test) Flaky() bool {
+	if t.Status != Passed {
+		return false
+	}
+	for _, attempt := range t.Attempts {
+		if attempt.Status == Failed || attempt.Status == Hung {
+			return true
+		}
+	}
+	return false
+}
+
+// FinalResult returns the result of the last attempt, nil if there is none
+func (t Test) FinalResult() *Result {
+	if n := len(t.Attempts); n > 0 {
+		return t.Attempts[n-1].Result
+	}
+	return nil
+}
+
+// Summary holds the counts of the run
+type Summary struct {
+	RunID       string    `json:"runId"`
+	StartTime   time.Time `json:"startTime"`
+	EndTime     time.Time `json:"endTime,omitempty"`
+	Elapsed     float64   `json:"elapsedSeconds"`
+	Total       int       `json:"total"`
+	Queued      int       `json:"queued"`
+	Running     int       `json:"running"`
+	Retrying    int       `json:"retrying"`
+	Passed      int       `json:"passed"`
+	Failed      int       `json:"failed"`
+	NotSelected int       `json:"notSelected"`
+	Stalled     int       `json:"stalled"`
+	Attempts    int       `json:"attempts"`
+}
+
+// Event is a lifecycle event of the run
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	RunID    string    `json:"runId,omitempty"`
+	TestID   string    `json:"testId,omitempty"`
+	Queue    string    `json:"queue,omitempty"`
+	Attempt  int       `json:"attempt,omitempty"`
+	Status   Status    `json:"status,omitempty"`
+	ExitCode *int      `json:"exitCode,omitempty"`
+	Duration float64   `json:"durationSeconds,omitempty"`
+	LogFile  string    `json:"logFile,omitempty"`
+	Hook     string    `json:"hook,omitempty"`
+	Command  string    `json:"command,omitempty"`
+	Summary  *Summary  `json:"summary,omitempty"`
+	Result   *Result   `json:"result,omitempty"`
+}
+
+// State is the concurrency safe state of a run
+type State struct {
+	mu          sync.RWMutex
+	runID       string
+	startTime   time.Time
+	endTime     time.Time
+	tests       map[string]*Test
+	order       []string
+	queueLength map[string]int
+	subscribers map[chan Event]struct{}
+	listeners   []func(Event)
+}
+
+// Current is the state of the run in progress
+var Current = New()
+
+func New() *State {
+	return &State{
+		tests:       make(map[string]*Test),
+		queueLength: make(map[string]int),
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Start marks the start of the run
+func (s *State) Start(runID string) {
+	s.mu.Lock()
+	s.runID = runID
+	s.startTime = time.Now()
+	s.mu.Unlock()
+	s.publish(Event{Type: "run_started", RunID: runID})
+}
+
+// Finish marks the end of the run
+func (s *State) Finish() {
+	s.mu.Lock()
+	s.endTime = time.Now()
+	s.mu.Unlock()
+	summary := s.Summary()
+	s.publish(Event{Type: "run_finished", RunID: summary.RunID, Summary: &summary})
+}
+
+// Queue adds a test case to the given queue
+func (s *State) Queue(testID, queue string) {
+	s.mu.Lock()
+	if _, ok := s.tests[testID]; !ok {
+		s.order = append(s.order, testID)
+	}
+	s.tests[testID] = &Test{ID: testID, Queue: queue, Status: Queued}
+	s.mu.Unlock()
+	s.publish(Event{Type: "test_queued", TestID: testID, Queue: queue, Status: Queued})
+}
+
+// SetQueueLength records the number of test cases run in parallel in the queue
+func (s *State) SetQueueLength(queue string, length int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queueLength[queue] = length
+}
+
+// ExpectedCompletion estimates when the run completes from the average
+// duration of the finished attempts, it returns the zero time if no attempt
+// has finished yet
+func (s *State) ExpectedCompletion() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var total float64
+	var finished int
+	pending := make(map[string]int)
+	for _, test := range s.tests {
+		for _, attempt := range test.Attempts {
+			if !attempt.EndTime.IsZero() {
+				total += attempt.Duration
+				finished++
+			}
+		}
+		switch test.Status {
+		case Queued, Running, Retrying:
+			pending[test.Queue]++
+		}
+	}
+	if finished == 0 {
+		return time.Time{}
+	}
+	if !s.endTime.IsZero() {
+		return s.endTime
+	}
+
+	average := total / float64(finished)
+	var remaining float64
+	for queue, count := range pending {
+		length := s.queueLength[queue]
+		if length < 1 {
+			length = 1
+		}
+		// queues run one after the other, each with its own parallelism
+		remaining += float64(count) * average / float64(length)
+	}
+	return time.Now().Add(time.Duration(remaining * float64(time.Second)))
+}
+
+// StartAttempt marks the test case as running
+func (s *State) StartAttempt(testID string, number int, logFile, artifactDir string) {
+	s.mu.Lock()
+	test := s.test(testID)
+	test.Status = Running
+	test.Attempts = append(test.Attempts, Attempt{
+		Number:      number,
+		Status:      Running,
+		StartTime:   time.Now(),
+		LogFile:     logFile,
+		ArtifactDir: artifactDir,
+	})
+	s.mu.Unlock()
+	s.publish(Event{Type: "attempt_started", TestID: testID, Attempt: number, Status: Running, LogFile: logFile})
+}
+
+// FinishAttempt records the result of the running attempt. A failed or hung attempt
+// keeps the test case running until Retry or Fail is called.
+func (s *State) FinishAttempt(testID string, exitCode int, status Status) {
+	s.mu.Lock()
+	test := s.test(testID)
+	var event Event
+	if n := len(test.Attempts); n > 0 {
+		attempt := &test.Attempts[n-1]
+		attempt.Status = status
+		attempt.ExitCode = exitCode
+		attempt.EndTime = time.Now()
+		attempt.Duration = attempt.EndTime.Sub(attempt.StartTime).Seconds()
+		event = Event{Type: "attempt_finished", TestID: testID, Attempt: attempt.Number, Status: status, ExitCode: &exitCode, Duration: attempt.Duration, Result: attempt.Result}
+	}
+	test.StalledSince = time.Time{}
+	if status != Failed && status != Hung {
+		test.Status = status
+	}
+	s.mu.Unlock()
+	s.publish(event)
+}
+
+// SetResult records the result the test framework reported for the running
+// attempt, it is called before FinishAttempt
+func (s *State) SetResult(testID string, result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	test := s.test(testID)
+	if n := len(test.Attempts); n > 0 {
+		test.Attempts[n-1].Result = &result
+	}
+}
+
+// StartIsolation marks the start of the isolated rerun of a test case that
+// failed for good, the test case keeps its final status
+func (s *State) StartIsolation(testID string, number int, logFile, artifactDir string) {
+	s.mu.Lock()
+	s.test(testID).Isolation = &Attempt{
+		Number:      number,
+		Status:      Running,
+		StartTime:   time.Now(),
+		LogFile:     logFile,
+		ArtifactDir: artifactDir,
+	}
+	s.mu.Unlock()
+	s.publish(Event{Type: "isolation_started", TestID: testID, Attempt: number, Status: Running, LogFile: logFile})
+}
+
+// SetIsolationResult records the result the test framework reported for the
+// isolated rerun, it is called before FinishIsolation
+func (s *State) SetIsolationResult(testID string, result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if isolation := s.test(testID).Isolation; isolation != nil {
+		isolation.Result = &result
+	}
+}
+
+// FinishIsolation records the result of the isolated rerun
+func (s *State) FinishIsolation(testID string, exitCode int, status Status) {
+	s.mu.Lock()
+	test := s.test(testID)
+	test.StalledSince = time.Time{}
+	isolation := test.Isolation
+	if isolation == nil {
+		s.mu.Unlock()
+		return
+	}
+	isolation.Status = status
+	isolation.ExitCode = exitCode
+	isolation.EndTime = time.Now()
+	isolation.Duration = isolation.EndTime.Sub(isolation.StartTime).Seconds()
+	event := Event{Type: "isolation_finished", TestID: testID, Attempt: isolation.Number, Status: status, ExitCode: &exitCode, Duration: isolation.Duration, Result: isolation.Result}
+	s.mu.Unlock()
+	s.publish(event)
+}
+
+// Retry marks the failed test case as queued for another attempt
+func (s *State) Retry(testID string) {
+	s.setStatus(testID, Retrying, "retry_scheduled")
+}
+
+// Fail marks the test case as failed for good
+func (s *State) Fail(testID string) {
+	s.setStatus(testID, Failed, "test_failed")
+}
+
+// HookExecuted records a hook command run for the attempt of the test case
+func (s *State) HookExecuted(hook, testID string, attempt int, command string, exitCode int, duration time.Duration) {
+	s.publish(Event{Type: "hook_executed", TestID: testID, Attempt: attempt, Hook: hook, Command: command, ExitCode: &exitCode, Duration: duration.Seconds()})
+}
+
+// Stall marks the running test case as stalled since its last output
+func (s *State) Stall(testID string, lastOutput time.Time) {
+	s.mu.Lock()
+	s.test(testID).StalledSince = lastOutput
+	s.mu.Unlock()
+	s.publish(Event{Type: "test_stalled", TestID: testID, Status: Running})
+}
+
+// Resume clears the stalled mark of the test case once it writes output again
+func (s *State) Resume(testID string) {
+	s.mu.Lock()
+	s.test(testID).StalledSince = time.Time{}
+	s.mu.Unlock()
+	s.publish(Event{Type: "test_resumed", TestID: testID, Status: Running})
+}
+
+func (s *State) setStatus(testID string, status Status, eventType string) {
+	s.mu.Lock()
+	s.test(testID).Status = status
+	s.mu.Unlock()
+	s.publish(Event{Type: eventType, TestID: testID, Status: status})
+}
+
+// test returns the test case, adding it if unknown. Callers hold the lock.
+func (s *State) test(testID string) *Test {
+	test, ok := s.tests[testID]
+	if !ok {
+		test = &Test{ID: testID, Status: Queued}
+		s.tests[testID] = test
+		s.order = append(s.order, testID)
+	}
+	return test
+}
+
+// Summary returns the counts of the run
+func (s *State) Summary() Summary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	summary := Summary{RunID: s.runID, StartTime: s.startTime, EndTime: s.endTime, Total: len(s.tests)}
+	if !s.startTime.IsZero() {
+		end := s.endTime
+		if end.IsZero() {
+			end = time.Now()
+		}
+		summary.Elapsed = end.Sub(s.startTime).Seconds()
+	}
+	for _, test := range s.tests {
+		summary.Attempts += len(test.Attempts)
+		if !test.StalledSince.IsZero() {
+			summary.Stalled++
+		}
+		switch test.Status {
+		case Queued:
+			summary.Queued++
+		case Running:
+			summary.Running++
+		case Retrying:
+			summary.Retrying++
+		case Passed:
+			summary.Passed++
+		case Failed:
+			summary.Failed++
+		case NotSelected:
+			summary.NotSelected++
+		}
+	}
+	return summary
+}
+
+// Tests returns a copy of every test case in queue order
+func (s *State) Tests() []Test {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tests := make([]Test, 0, len(s.order))
+	for _, testID := range s.order {
+		tests = append(tests, copyTest(s.tests[testID]))
+	}
+	return tests
+}
+
+// Test returns a copy of the test case
+func (s *State) Test(testID string) (Test, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	test, ok := s.tests[testID]
+	if !ok {
+		return Test{}, false
+	}
+	return copyTest(test), true
+}
+
+func copyTest(test *Test) Test {
+	c := *test
+	c.Attempts = append([]Attempt(nil), test.Attempts...)
+	if test.Isolation != nil {
+		isolation := *test.Isolation
+		c.Isolation = &isolation
+	}
+	return c
+}
+
+// Subscribe returns a channel receiving the events of the run and a
+// function to stop receiving them
+func (s *State) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subscribers, ch)
+			s.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Listen registers a function called with every event of the run. Unlike
+// subscribers, listeners never miss an event, they are called synchronously
+// and must be quick.
+func (s *State) Listen(listener func(Event)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+func (s *State) publish(event Event) {
+	if event.Type == "" {
+		return
+	}
+	event.Time = time.Now()
+
+	s.mu.RLock()
+	listeners := s.listeners
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// drop the event rather than block the launcher on a slow subscriber
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
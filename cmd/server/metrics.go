@@ -0,0 +1,142 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package server
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/vavuthu/itr/cmd/runstate"
+)
+
+var (
+	testsDesc = prometheus.NewDesc("itr_tests",
+		"Number of test cases by status.", []string{"run_id", "queue", "status"}, nil)
+	queueDepthDesc = prometheus.NewDesc("itr_queue_depth",
+		"Number of test cases waiting to run, including the ones waiting for a retry.", []string{"run_id", "queue"}, nil)
+	runningDesc = prometheus.NewDesc("itr_running_tests",
+		"Number of test cases running.", []string{"run_id", "queue"}, nil)
+	stalledDesc = prometheus.NewDesc("itr_stalled_tests",
+		"Number of running test cases which wrote no output for the hang timeout.", []string{"run_id", "queue"}, nil)
+	retriesDesc = prometheus.NewDesc("itr_retries_total",
+		"Number of retried test case attempts.", []string{"run_id", "queue"}, nil)
+	durationDesc = prometheus.NewDesc("itr_test_duration_seconds",
+		"Duration of the finished test case attempts.", []string{"run_id", "queue", "status"}, nil)
+	startTimeDesc = prometheus.NewDesc("itr_run_start_time_seconds",
+		"Start time of the run since unix epoch.", []string{"run_id"}, nil)
+	completionDesc = prometheus.NewDesc("itr_run_expected_completion_time_seconds",
+		"Expected completion time of the run since unix epoch, estimated from the average attempt duration.", []string{"run_id"}, nil)
+
+	durationBuckets = []float64{30, 60, 120, 300, 600, 1200, 1800, 3600, 7200, 14400}
+
+	allStatuses = []runstate.Status{runstate.Queued, runstate.Running, runstate.Retrying, runstate.Passed, runstate.Failed, runstate.NotSelected}
+)
+
+// collector exposes the run state as Prometheus metrics, they are computed
+// from the run state on every scrape
+type collector struct {
+	state *runstate.State
+}
+
+type histogram struct {
+	count   uint64
+	sum     float64
+	buckets map[float64]uint64
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{testsDesc, queueDepthDesc, runningDesc, stalledDesc, retriesDesc, durationDesc, startTimeDesc, completionDesc} {
+		ch <- desc
+	}
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	summary := c.state.Summary()
+	runID := summary.RunID
+
+	counts := make(map[string]map[runstate.Status]int)
+	retries := make(map[string]int)
+	stalled := make(map[string]int)
+	histograms := make(map[[2]string]*histogram)
+	for _, test := range c.state.Tests() {
+		if counts[test.Queue] == nil {
+			counts[test.Queue] = make(map[runstate.Status]int)
+		}
+		counts[test.Queue][test.Status]++
+		if !test.StalledSince.IsZero() {
+			stalled[test.Queue]++
+		}
+		if len(test.Attempts) > 1 {
+			retries[test.Queue] += len(test.Attempts) - 1
+		}
+		for _, attempt := range test.Attempts {
+			if attempt.EndTime.IsZero() {
+				continue
+			}
+			key := [2]string{test.Queue, string(attempt.Status)}
+			h := histograms[key]
+			if h == nil {
+				h = &histogram{buckets: make(map[float64]uint64)}
+				histograms[key] = h
+			}
+			h.count++
+			h.sum += attempt.Duration
+			for _, bucket := range durationBuckets {
+				if attempt.Duration <= bucket {
+					h.buckets[bucket]++
+				}
+			}
+		}
+	}
+
+	queues := make([]string, 0, len(counts))
+	for queue := range counts {
+		queues = append(queues, queue)
+	}
+	sort.Strings(queues)
+	for _, queue := range queues {
+		for _, status := range allStatuses {
+			ch <- prometheus.MustNewConstMetric(testsDesc, prometheus.GaugeValue, float64(counts[queue][status]), runID, queue, string(status))
+		}
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(counts[queue][runstate.Queued]+counts[queue][runstate.Retrying]), runID, queue)
+		ch <- prometheus.MustNewConstMetric(runningDesc, prometheus.GaugeValue, float64(counts[queue][runstate.Running]), runID, queue)
+		ch <- prometheus.MustNewConstMetric(stalledDesc, prometheus.GaugeValue, float64(stalled[queue]), runID, queue)
+		ch <- prometheus.MustNewConstMetric(retriesDesc, prometheus.CounterValue, float64(retries[queue]), runID, queue)
+	}
+
+	for key, h := range histograms {
+		ch <- prometheus.MustNewConstHistogram(durationDesc, h.count, h.sum, h.buckets, runID, key[0], key[1])
+	}
+
+	if !summary.StartTime.IsZero() {
+		ch <- prometheus.MustNewConstMetric(startTimeDesc, prometheus.GaugeValue, float64(summary.StartTime.Unix()), runID)
+	}
+	if expected := c.state.ExpectedCompletion(); !expected.IsZero() {
+		ch <- prometheus.MustNewConstMetric(completionDesc, prometheus.GaugeValue, float64(expected.Unix()), runID)
+	}
+}
+
+// metricsHandler serves the metrics of the run state in Prometheus format
+func metricsHandler(state *runstate.State) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&collector{state: state})
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
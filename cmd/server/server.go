@@ -0,0 +1,210 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/vavuthu/itr/cmd/runstate"
+	"github.com/vavuthu/itr/cmd/utils"
+	"github.com/vavuthu/itr/logger"
+)
+
+const (
+	// defaultLogTail is the number of bytes of the log returned when tail is not given
+	defaultLogTail = 64 * 1024
+	// maxLogTail caps the bytes of the log returned in a single request
+	maxLogTail = 8 * 1024 * 1024
+	keepAliveInterval = 15 * time.Second
+)
+
+// NewMux returns the handler serving the dashboard, the status API and the metrics
+func NewMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handleDashboard)
+	mux.HandleFunc("/api/summary", handleSummary)
+	mux.HandleFunc("/api/tests", handleTests)
+	mux.HandleFunc("/api/test", handleTest)
+	mux.HandleFunc("/api/events", handleEvents)
+	mux.HandleFunc("/api/log", handleLog)
+	mux.Handle("/metrics", metricsHandler(runstate.Current))
+	return mux
+}
+
+// Start serves the status API on addr in the background
+func Start(addr string, mux *http.ServeMux) *http.Server {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		logger.Infof("Dashboard and status API listening on http://%s/", addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("Status API stopped: %v", err)
+		}
+	}()
+	return srv
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Debugf("Failed to write response: %v", err)
+	}
+}
+
+// handleSummary returns the counts of the run
+func handleSummary(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, runstate.Current.Summary())
+}
+
+// handleTests returns every test case with its attempts, ?status= filters by status
+func handleTests(w http.ResponseWriter, r *http.Request) {
+	tests := runstate.Current.Tests()
+	if status := r.URL.Query().Get("status"); status != "" {
+		filtered := tests[:0]
+		for _, test := range tests {
+			if string(test.Status) == status {
+				filtered = append(filtered, test)
+			}
+		}
+		tests = filtered
+	}
+	writeJSON(w, tests)
+}
+
+// handleTest returns the test case given with ?id=
+func handleTest(w http.ResponseWriter, r *http.Request) {
+	test, ok := runstate.Current.Test(r.URL.Query().Get("id"))
+	if !ok {
+		http.Error(w, "test case not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, test)
+}
+
+// handleEvents streams the lifecycle events of the run as Server-Sent Events
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := runstate.Current.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// handleLog returns the tail of the log of a test case attempt, ?id= selects
+// the test case, ?attempt= the attempt (latest by default) and ?tail= the
+// number of bytes, color codes are stripped and secrets masked as the server
+// has no authentication
+func handleLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	test, ok := runstate.Current.Test(query.Get("id"))
+	if !ok || len(test.Attempts) == 0 {
+		http.Error(w, "test case not found or not started", http.StatusNotFound)
+		return
+	}
+
+	attempt := test.Attempts[len(test.Attempts)-1]
+	if n := query.Get("attempt"); n != "" {
+		number, err := strconv.Atoi(n)
+		if err != nil || number < 1 || number > len(test.Attempts) {
+			http.Error(w, "invalid attempt", http.StatusBadRequest)
+			return
+		}
+		attempt = test.Attempts[number-1]
+	}
+
+	tail := int64(defaultLogTail)
+	if t := query.Get("tail"); t != "" {
+		n, err := strconv.ParseInt(t, 10, 64)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid tail", http.StatusBadRequest)
+			return
+		}
+		tail = n
+	}
+	if tail > maxLogTail {
+		tail = maxLogTail
+	}
+
+	file, err := os.Open(attempt.LogFile)
+	if err != nil {
+		http.Error(w, "log not available", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "log not available", http.StatusNotFound)
+		return
+	}
+	offset := info.Size() - tail
+	if offset < 0 {
+		offset = 0
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Log-Size", strconv.FormatInt(info.Size(), 10))
+	w.Header().Set("X-Log-Offset", strconv.FormatInt(offset, 10))
+	io.WriteString(w, utils.ReadMasked(file, offset, info.Size()))
+}
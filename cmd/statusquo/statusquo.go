@@ -18,20 +18,13 @@ limitations under the License.
 package statusquo
 
 import (
-	"runtime"
 	"sync"
 	"time"
 	
+	"github.com/vavuthu/itr/cmd/runstate"
 	"github.com/vavuthu/itr/logger"
 )
 
-var (
-	TestCasesPassed 	int
-	TestCasesFailed 	int
-	TestCasesNotSelected int
-	TotalTestCases 		int
-)
-
 // status quo for the test case execution
 func Statusquo(wg *sync.WaitGroup, stopChannel <-chan bool) {
 	defer func()  {
@@ -47,24 +40,25 @@ func Statusquo(wg *sync.WaitGroup, stopChannel <-chan bool) {
 			printStatus()
 			return
 		case <-ticker.C:
-			// main function and Statusquo are the goroutines
-			// exit if there is no test case execution
-			if runtime.NumGoroutine() == 2 {
-				printStatus()
-				return
-			}
 			printStatus()
 		}
 	}
 }
 
 func printStatus() {
-	tcRunning := runtime.NumGoroutine() - 2
-	toExecute := TotalTestCases - TestCasesPassed - TestCasesFailed - TestCasesNotSelected - tcRunning
-	logger.Info("Total Test cases:", TotalTestCases)
-	logger.Info("Passed:", TestCasesPassed)
-	logger.Info("Failed:", TestCasesFailed)
-	logger.Info("Not selected:", TestCasesNotSelected)
-	logger.Info("Test cases running:", tcRunning)
-	logger.Info("To Execute:", toExecute)
+	summary := runstate.Current.Summary()
+	logger.Info("Total Test cases:", summary.Total)
+	logger.Info("Passed:", summary.Passed)
+	logger.Info("Failed:", summary.Failed)
+	logger.Info("Not selected:", summary.NotSelected)
+	logger.Info("Test cases running:", summary.Running)
+	logger.Info("To Execute:", summary.Queued+summary.Retrying)
+	if summary.Stalled > 0 {
+		logger.Warn("Stalled:", summary.Stalled)
+		for _, test := range runstate.Current.Tests() {
+			if !test.StalledSince.IsZero() {
+				logger.Warnf("test case: %s has written no output for %s", test.ID, time.Since(test.StalledSince).Round(time.Second))
+			}
+		}
+	}
 }
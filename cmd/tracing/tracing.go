@@ -0,0 +1,266 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/vavuthu/itr/cmd/runstate"
+	"github.com/vavuthu/itr/logger"
+)
+
+const (
+	serviceName     = "itr"
+	tracerName      = "github.com/vavuthu/itr"
+	shutdownTimeout = 30 * time.Second
+)
+
+// Options configures where the traces are exported to
+type Options struct {
+	// Endpoint is the OTLP/HTTP endpoint, e.g. http://tempo:4318
+	Endpoint string
+	// File is the path of a file the spans are written to as JSON
+	File string
+	// Cluster annotates every span with the cluster the tests run against
+	Cluster string
+}
+
+// tracer follows the events of the run state and turns them into spans
+type tracer struct {
+	mu       sync.Mutex
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+	cluster  string
+	run      trace.Span
+	runCtx   context.Context
+	queues   map[string]string
+	attempts map[string]trace.Span
+}
+
+var active *tracer
+
+// Enabled reports whether the options ask for any exporter
+func (o Options) Enabled() bool {
+	return o.Endpoint != "" || o.File != ""
+}
+
+// Start exports one trace per run with a span per test case attempt and hook,
+// it does nothing if no exporter is configured
+func Start(state *runstate.State, opts Options) error {
+	if !opts.Enabled() {
+		return nil
+	}
+
+	var providerOpts []sdktrace.TracerProviderOption
+	if opts.Endpoint != "" {
+		exporter, err := otlptracehttp.New(context.Background(), endpointOptions(opts.Endpoint)...)
+		if err != nil {
+			return fmt.Errorf("failed to create OTLP exporter: %v", err)
+		}
+		providerOpts = append(providerOpts, sdktrace.WithBatcher(exporter))
+	}
+	if opts.File != "" {
+		file, err := os.Create(opts.File)
+		if err != nil {
+			return fmt.Errorf("failed to create trace file: %v", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			return fmt.Errorf("failed to create trace file exporter: %v", err)
+		}
+		providerOpts = append(providerOpts, sdktrace.WithBatcher(exporter))
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		return fmt.Errorf("failed to create trace resource: %v", err)
+	}
+	providerOpts = append(providerOpts, sdktrace.WithResource(res))
+	provider := sdktrace.NewTracerProvider(providerOpts...)
+
+	active = &tracer{
+		provider: provider,
+		tracer:   provider.Tracer(tracerName),
+		cluster:  opts.Cluster,
+		queues:   make(map[string]string),
+		attempts: make(map[string]trace.Span),
+	}
+	state.Listen(active.handle)
+	return nil
+}
+
+// endpointOptions returns the OTLP options of an endpoint URL, plain http
+// endpoints are sent to without TLS
+func endpointOptions(endpoint string) []otlptracehttp.Option {
+	var opts []otlptracehttp.Option
+	if strings.HasPrefix(endpoint, "http://") {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	endpoint = strings.TrimPrefix(strings.TrimPrefix(endpoint, "http://"), "https://")
+	host, path, found := strings.Cut(endpoint, "/")
+	opts = append(opts, otlptracehttp.WithEndpoint(host))
+	if found && path != "" {
+		opts = append(opts, otlptracehttp.WithURLPath("/"+path))
+	}
+	return opts
+}
+
+// Phase starts a span for a phase of the run such as the report generation,
+// the returned function ends it
+func Phase(name string) func(err error) {
+	if active == nil {
+		return func(error) {}
+	}
+	active.mu.Lock()
+	ctx := active.runCtx
+	active.mu.Unlock()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, span := active.tracer.Start(ctx, name, trace.WithAttributes(active.clusterAttributes()...))
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// Shutdown ends the run span and flushes the spans to the exporters
+func Shutdown() {
+	if active == nil {
+		return
+	}
+	active.mu.Lock()
+	for key, span := range active.attempts {
+		span.End()
+		delete(active.attempts, key)
+	}
+	if active.run != nil {
+		active.run.End()
+	}
+	active.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := active.provider.Shutdown(ctx); err != nil {
+		logger.Errorf("Failed to export traces: %v", err)
+	}
+}
+
+func (t *tracer) clusterAttributes() []attribute.KeyValue {
+	if t.cluster == "" {
+		return nil
+	}
+	return []attribute.KeyValue{attribute.String("itr.cluster", t.cluster)}
+}
+
+func (t *tracer) handle(event runstate.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch event.Type {
+	case "run_started":
+		t.runCtx, t.run = t.tracer.Start(context.Background(), "itr run",
+			trace.WithTimestamp(event.Time),
+			trace.WithAttributes(append(t.clusterAttributes(), attribute.String("itr.run_id", event.RunID))...))
+	case "test_queued":
+		t.queues[event.TestID] = event.Queue
+	case "attempt_started", "isolation_started":
+		name := "test attempt"
+		if event.Type == "isolation_started" {
+			name = "isolated rerun"
+		}
+		_, span := t.tracer.Start(t.context(), name,
+			trace.WithTimestamp(event.Time),
+			trace.WithAttributes(append(t.clusterAttributes(),
+				attribute.String("itr.test_id", event.TestID),
+				attribute.String("itr.queue", t.queues[event.TestID]),
+				attribute.Int("itr.attempt", event.Attempt),
+			)...))
+		t.attempts[attemptKey(event.TestID, event.Attempt)] = span
+	case "attempt_finished", "isolation_finished":
+		key := attemptKey(event.TestID, event.Attempt)
+		span, ok := t.attempts[key]
+		if !ok {
+			return
+		}
+		delete(t.attempts, key)
+		span.SetAttributes(attribute.String("itr.result", string(event.Status)))
+		if event.ExitCode != nil {
+			span.SetAttributes(attribute.Int("itr.exit_code", *event.ExitCode))
+		}
+		if event.Status == runstate.Failed || event.Status == runstate.Hung {
+			span.SetStatus(codes.Error, string(event.Status))
+		}
+		span.End(trace.WithTimestamp(event.Time))
+	case "hook_executed":
+		// hooks run within an attempt, the event is sent once the hook is done
+		ctx := t.context()
+		if span, ok := t.attempts[attemptKey(event.TestID, event.Attempt)]; ok {
+			ctx = trace.ContextWithSpan(ctx, span)
+		}
+		start := event.Time.Add(-time.Duration(event.Duration * float64(time.Second)))
+		_, span := t.tracer.Start(ctx, "hook "+event.Hook,
+			trace.WithTimestamp(start),
+			trace.WithAttributes(append(t.clusterAttributes(),
+				attribute.String("itr.hook", event.Hook),
+				attribute.String("itr.test_id", event.TestID),
+				attribute.Int("itr.attempt", event.Attempt),
+				attribute.String("itr.command", logger.Mask(event.Command)),
+			)...))
+		if event.ExitCode != nil && *event.ExitCode != 0 {
+			span.SetAttributes(attribute.Int("itr.exit_code", *event.ExitCode))
+			span.SetStatus(codes.Error, "hook failed")
+		}
+		span.End(trace.WithTimestamp(event.Time))
+	case "retry_scheduled", "test_failed", "test_stalled", "test_resumed":
+		if t.run == nil {
+			return
+		}
+		t.run.AddEvent(event.Type, trace.WithTimestamp(event.Time), trace.WithAttributes(attribute.String("itr.test_id", event.TestID)))
+	}
+}
+
+// context returns the context of the run span, spans started before the run
+// are roots of their own
+func (t *tracer) context() context.Context {
+	if t.runCtx == nil {
+		return context.Background()
+	}
+	return t.runCtx
+}
+
+func attemptKey(testID string, attempt int) string {
+	return fmt.Sprintf("%s#%d", testID, attempt)
+}
@@ -0,0 +1,205 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/progress"
+	"github.com/jedib0t/go-pretty/v6/text"
+
+	"github.com/vavuthu/itr/cmd/runstate"
+	"github.com/vavuthu/itr/logger"
+)
+
+const (
+	refreshInterval = time.Second
+	messageWidth = 80
+)
+
+// ui renders the progress of the run on the terminal
+type ui struct {
+	state    *runstate.State
+	writer   progress.Writer
+	overall  *progress.Tracker
+	attempts map[string]*progress.Tracker
+	finished map[string]bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+var (
+	active *ui
+	activeLock sync.Mutex
+)
+
+// IsTerminal reports whether the file is a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Start renders the progress of the run instead of the console log. It
+// returns false and leaves the console log alone when stdout is not a
+// terminal, e.g. in Jenkins.
+func Start(state *runstate.State) bool {
+	if !IsTerminal(os.Stdout) {
+		logger.Info("stdout is not a terminal, progress UI disabled")
+		return false
+	}
+
+	pw := progress.NewWriter()
+	pw.SetOutputWriter(os.Stdout)
+	pw.SetAutoStop(false)
+	pw.SetMessageWidth(messageWidth)
+	pw.SetTrackerLength(30)
+	pw.SetUpdateFrequency(250 * time.Millisecond)
+	pw.SetStyle(progress.StyleDefault)
+	pw.ShowOverallTracker(false)
+	pw.ShowETA(true)
+	pw.ShowTime(true)
+	pw.Style().Visibility.Value = false
+	pw.Style().Options.TimeInProgressPrecision = time.Second
+	pw.Style().Options.TimeDonePrecision = time.Second
+
+	u := &ui{
+		state:    state,
+		writer:   pw,
+		overall:  &progress.Tracker{Message: "Overall", Units: progress.UnitsDefault},
+		attempts: make(map[string]*progress.Tracker),
+		finished: make(map[string]bool),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	pw.AppendTracker(u.overall)
+
+	logger.Infof("Progress UI started, the log is written to the log file only")
+	logger.SetConsole(false)
+	go pw.Render()
+	go u.run()
+
+	activeLock.Lock()
+	active = u
+	activeLock.Unlock()
+	return true
+}
+
+// Stop stops the progress UI, if started, and turns the console log back on
+func Stop() {
+	activeLock.Lock()
+	u := active
+	active = nil
+	activeLock.Unlock()
+	if u == nil {
+		return
+	}
+
+	close(u.stop)
+	<-u.done
+	logger.SetConsole(true)
+}
+
+func (u *ui) run() {
+	defer close(u.done)
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-u.stop:
+			u.refresh()
+			u.overall.MarkAsDone()
+			// let the writer render the final state before stopping it
+			time.Sleep(500 * time.Millisecond)
+			u.writer.Stop()
+			for u.writer.IsRenderInProgress() {
+				time.Sleep(50 * time.Millisecond)
+			}
+			return
+		case <-ticker.C:
+			u.refresh()
+		}
+	}
+}
+
+// refresh adds a row for every started attempt and finishes the rows of
+// the finished ones, finished rows scroll up as recent results
+func (u *ui) refresh() {
+	summary := u.state.Summary()
+	u.overall.UpdateTotal(int64(summary.Total))
+	u.overall.SetValue(int64(summary.Passed + summary.Failed + summary.NotSelected))
+	u.writer.SetPinnedMessages(fmt.Sprintf("Passed: %s  Failed: %s  Not selected: %d  Running: %d  Stalled: %s  Retrying: %d  Queued: %d",
+		text.FgGreen.Sprint(summary.Passed), text.FgRed.Sprint(summary.Failed), summary.NotSelected,
+		summary.Running, text.FgYellow.Sprint(summary.Stalled), summary.Retrying, summary.Queued))
+
+	for _, test := range u.state.Tests() {
+		for _, attempt := range test.Attempts {
+			key := fmt.Sprintf("%s#%d", test.ID, attempt.Number)
+			tracker, tracked := u.attempts[key]
+			if attempt.EndTime.IsZero() {
+				if !tracked {
+					tracker = &progress.Tracker{Message: message(test.ID, attempt.Number, "")}
+					u.attempts[key] = tracker
+					u.writer.AppendTracker(tracker)
+					tracker.Start()
+				}
+				if test.StalledSince.IsZero() {
+					tracker.UpdateMessage(message(test.ID, attempt.Number, ""))
+				} else {
+					tracker.UpdateMessage(message(test.ID, attempt.Number, "STALLED"))
+				}
+				continue
+			}
+			if u.finished[key] {
+				continue
+			}
+			u.finished[key] = true
+			result := message(test.ID, attempt.Number, strings.ToUpper(string(attempt.Status)))
+			if !tracked {
+				// the attempt started and finished between two refreshes
+				u.writer.Log("%s in %s", result, time.Duration(attempt.Duration*float64(time.Second)).Round(time.Second))
+				continue
+			}
+			tracker.UpdateMessage(result)
+			if attempt.Status == runstate.Failed || attempt.Status == runstate.Hung {
+				tracker.MarkAsErrored()
+			} else {
+				tracker.MarkAsDone()
+			}
+			delete(u.attempts, key)
+		}
+	}
+}
+
+// message returns the row message of an attempt, long test case IDs are
+// cut from the left so the test name stays visible
+func message(testID string, attempt int, result string) string {
+	suffix := fmt.Sprintf(" #%d", attempt)
+	if result != "" {
+		suffix += " " + result
+	}
+	width := messageWidth - len(suffix)
+	if len(testID) > width {
+		testID = "..." + testID[len(testID)-width+3:]
+	}
+	return testID + suffix
+}
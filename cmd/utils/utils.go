@@ -22,6 +22,8 @@ import (
 	"errors"
 	"io"
 	"os"
+	"regexp"
+	"strings"
 
 	"github.com/vavuthu/itr/logger"
 )
@@ -73,3 +75,50 @@ func CheckFileExists(filepath string) bool {
 	_, err := os.Stat(filepath)
 	return !errors.Is(err, os.ErrNotExist)
 }
+
+// ansiEscapes are the terminal color codes in test logs
+var ansiEscapes = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
+
+// LogTail returns the last maxLines lines of the last maxBytes of a log file,
+// all of them if maxLines is 0, without color codes and with secrets masked
+func LogTail(path string, maxBytes int64, maxLines int) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return ""
+	}
+	offset := info.Size() - maxBytes
+	if offset < 0 {
+		offset = 0
+	}
+	output := ReadMasked(file, offset, info.Size())
+	if maxLines == 0 {
+		return output
+	}
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ReadMasked returns the bytes from offset to end of a log file without color
+// codes and with secrets masked, a secret cut off at offset is masked too
+func ReadMasked(file io.ReaderAt, offset, end int64) string {
+	start := offset - int64(logger.SecretLookback())
+	if start < 0 {
+		start = 0
+	}
+	content, _ := io.ReadAll(io.NewSectionReader(file, start, end-start))
+	if int64(len(content)) < offset-start {
+		return ""
+	}
+	lookback := ansiEscapes.ReplaceAll(content[:offset-start], nil)
+	window := ansiEscapes.ReplaceAll(content[offset-start:], nil)
+	return logger.MaskFrom(string(lookback)+string(window), len(lookback))
+}
@@ -20,9 +20,20 @@ package config
 type Config struct {
 	ConfigDir string
 	EmailID string
+	OutputDir string
 	RunID string
 	Subject string
 	Retry int
+	JUnitXML bool // Test cases write JUnit XML, merged into a single report
+	IsolateFailures bool // Rerun the parallel test cases that failed for good one at a time
+	Reports []Report // Reports generated at the end of the run
+	HistoryDB string // History database the run is recorded in, empty to disable it
+	Container ContainerOptions // Run level container options
+	ContainerEnv EnvOptions // Run level container environment
+	Manifest *Manifest
+	Hang HangOptions // Detection of test cases not writing any output
+	Hooks Hooks // Commands run on the hook events
+	OmitLogs bool // Log output is left out of the reports as its secrets can't be masked
 	Env map[string]interface{} // For dynamic parameters
 }
 
@@ -0,0 +1,125 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultEnvPassthrough are the Jenkins variables passed to every test container
+var DefaultEnvPassthrough = []string{"BUILD_NUMBER", "BUILD_TAG", "BUILD_URL", "JOB_NAME", "NODE_NAME", "WORKSPACE"}
+
+// secretEnvMarkers are the name fragments which mark a variable as secret
+// even if it is not listed explicitly
+var secretEnvMarkers = []string{"PASSWORD", "PASSWD", "TOKEN", "SECRET", "KEY"}
+
+// EnvOptions holds the environment passed to the test containers
+type EnvOptions struct {
+	// Passthrough lists the variables of ITR's own environment passed to the
+	// containers, an entry ending with * matches every variable with that prefix
+	Passthrough []string
+	// Env holds the variables set explicitly for all the containers
+	Env map[string]string
+	// Secrets lists the variables whose values are masked in logs and reports
+	Secrets []string
+}
+
+// ParseEnvPairs parses KEY=VALUE pairs
+func ParseEnvPairs(pairs []string) (map[string]string, error) {
+	env := make(map[string]string)
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid env %q, expected KEY=VALUE", pair)
+		}
+		env[key] = value
+	}
+	return env, nil
+}
+
+// ParseEnvFile parses a file of KEY=VALUE lines, blank lines and lines
+// starting with # are ignored
+func ParseEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open env file %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var pairs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, found := strings.Cut(line, "=")
+		if found && len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		if found {
+			line = key + "=" + value
+		}
+		pairs = append(pairs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file %s: %v", path, err)
+	}
+
+	env, err := ParseEnvPairs(pairs)
+	if err != nil {
+		return nil, fmt.Errorf("env file %s: %v", path, err)
+	}
+	return env, nil
+}
+
+// IsSecret reports whether the value of the variable must be masked
+func (o EnvOptions) IsSecret(name string) bool {
+	for _, secret := range o.Secrets {
+		if secret == name {
+			return true
+		}
+	}
+	upper := strings.ToUpper(name)
+	for _, marker := range secretEnvMarkers {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesPassthrough reports whether the variable is passed through from
+// ITR's own environment
+func (o EnvOptions) MatchesPassthrough(name string) bool {
+	for _, entry := range o.Passthrough {
+		if prefix, ok := strings.CutSuffix(entry, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if entry == name {
+			return true
+		}
+	}
+	return false
+}
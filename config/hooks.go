@@ -0,0 +1,108 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Hook events
+const (
+	// HookHang runs when a test case stops writing output
+	HookHang = "hang"
+)
+
+var hookEvents = map[string]bool{
+	HookHang: true,
+}
+
+// Hang policies, each one does what the previous does as well
+const (
+	// HangWarn only logs the stalled test case
+	HangWarn = "warn"
+	// HangDump runs the hang hooks to dump the container state
+	HangDump = "dump"
+	// HangKill kills the container and fails the attempt as hung
+	HangKill = "kill"
+)
+
+var hangPolicies = map[string]bool{
+	HangWarn: true,
+	HangDump: true,
+	HangKill: true,
+}
+
+// HangOptions configures the detection of test cases not writing any output
+type HangOptions struct {
+	// Timeout is the time without output after which a test case is stalled,
+	// zero disables the detection
+	Timeout time.Duration
+	Policy  string
+}
+
+// Validate checks the policy is known
+func (o HangOptions) Validate() error {
+	if o.Timeout < 0 {
+		return fmt.Errorf("invalid hang timeout %s", o.Timeout)
+	}
+	if !hangPolicies[o.Policy] {
+		return fmt.Errorf("invalid hang policy %q, expected %s, %s or %s", o.Policy, HangWarn, HangDump, HangKill)
+	}
+	return nil
+}
+
+// Hooks maps the hook events to the shell commands run on them
+type Hooks map[string][]string
+
+// ParseHooks parses EVENT=COMMAND pairs
+func ParseHooks(pairs []string) (Hooks, error) {
+	hooks := make(Hooks)
+	for _, pair := range pairs {
+		event, command, found := strings.Cut(pair, "=")
+		event = strings.TrimSpace(event)
+		if !found || strings.TrimSpace(command) == "" {
+			return nil, fmt.Errorf("invalid hook %q, expected EVENT=COMMAND", pair)
+		}
+		hooks[event] = append(hooks[event], command)
+	}
+	return hooks, hooks.Validate()
+}
+
+// Validate checks the hook events are known
+func (h Hooks) Validate() error {
+	for event := range h {
+		if !hookEvents[event] {
+			return fmt.Errorf("unknown hook event %q", event)
+		}
+	}
+	return nil
+}
+
+// Merge returns the hooks with the commands of other appended
+func (h Hooks) Merge(other Hooks) Hooks {
+	merged := make(Hooks)
+	for event, commands := range h {
+		merged[event] = append(merged[event], commands...)
+	}
+	for event, commands := range other {
+		merged[event] = append(merged[event], commands...)
+	}
+	return merged
+}
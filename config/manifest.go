@@ -0,0 +1,146 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ContainerOptions holds the podman runtime options applied to test containers
+type ContainerOptions struct {
+	CPUs      string   `yaml:"cpus,omitempty" json:"cpus,omitempty"`
+	Memory    string   `yaml:"memory,omitempty" json:"memory,omitempty"`
+	PidsLimit int      `yaml:"pidsLimit,omitempty" json:"pidsLimit,omitempty"`
+	Network   string   `yaml:"network,omitempty" json:"network,omitempty"`
+	Volumes   []string `yaml:"volumes,omitempty" json:"volumes,omitempty"`
+	ExtraArgs []string `yaml:"extraArgs,omitempty" json:"extraArgs,omitempty"`
+}
+
+// TestConfig holds the settings that apply to a single test case
+type TestConfig struct {
+	Container ContainerOptions  `yaml:"container,omitempty"`
+	Env       map[string]string `yaml:"env,omitempty"`
+}
+
+// Manifest is the run level configuration file passed with --manifest
+type Manifest struct {
+	Container      ContainerOptions      `yaml:"container,omitempty"`
+	Env            map[string]string     `yaml:"env,omitempty"`
+	EnvPassthrough []string              `yaml:"envPassthrough,omitempty"`
+	SecretEnv      []string              `yaml:"secretEnv,omitempty"`
+	Hooks          Hooks                 `yaml:"hooks,omitempty"`
+	Tests          map[string]TestConfig `yaml:"tests,omitempty"`
+}
+
+var volumeOptions = map[string]bool{
+	"ro": true,
+	"rw": true,
+	"z":  true,
+	"Z":  true,
+}
+
+// LoadManifest reads and validates the manifest file
+func LoadManifest(path string) (*Manifest, error) {
+	manifest := &Manifest{}
+	if path == "" {
+		return manifest, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %v", path, err)
+	}
+
+	if err := yaml.Unmarshal(content, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %v", path, err)
+	}
+
+	if err := manifest.Container.Validate(); err != nil {
+		return nil, fmt.Errorf("manifest %s: %v", path, err)
+	}
+	if err := manifest.Hooks.Validate(); err != nil {
+		return nil, fmt.Errorf("manifest %s: %v", path, err)
+	}
+	for testCase, testConfig := range manifest.Tests {
+		if err := testConfig.Container.Validate(); err != nil {
+			return nil, fmt.Errorf("manifest %s: test case %s: %v", path, testCase, err)
+		}
+	}
+
+	return manifest, nil
+}
+
+// ForTest returns the settings of the given test case, empty if it is not listed
+func (m *Manifest) ForTest(testCase string) TestConfig {
+	if m == nil {
+		return TestConfig{}
+	}
+	return m.Tests[testCase]
+}
+
+// Validate checks the volumes are in podman source:target[:options] form
+func (o ContainerOptions) Validate() error {
+	for _, volume := range o.Volumes {
+		parts := strings.Split(volume, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid volume %q, expected source:target[:options]", volume)
+		}
+		if len(parts) == 3 {
+			for _, option := range strings.Split(parts[2], ",") {
+				if !volumeOptions[option] {
+					return fmt.Errorf("invalid option %q in volume %q", option, volume)
+				}
+			}
+		}
+	}
+	if o.PidsLimit < 0 {
+		return fmt.Errorf("invalid pids limit %d", o.PidsLimit)
+	}
+	return nil
+}
+
+// Merge returns the options with the non empty fields of override applied,
+// volumes and extra args of override are appended
+func (o ContainerOptions) Merge(override ContainerOptions) ContainerOptions {
+	merged := o
+	if override.CPUs != "" {
+		merged.CPUs = override.CPUs
+	}
+	if override.Memory != "" {
+		merged.Memory = override.Memory
+	}
+	if override.PidsLimit != 0 {
+		merged.PidsLimit = override.PidsLimit
+	}
+	if override.Network != "" {
+		merged.Network = override.Network
+	}
+	merged.Volumes = append(append([]string{}, o.Volumes...), override.Volumes...)
+	merged.ExtraArgs = append(append([]string{}, o.ExtraArgs...), override.ExtraArgs...)
+	return merged
+}
+
+// IsEmpty reports whether no option is set
+func (o ContainerOptions) IsEmpty() bool {
+	return o.CPUs == "" && o.Memory == "" && o.PidsLimit == 0 && o.Network == "" &&
+		len(o.Volumes) == 0 && len(o.ExtraArgs) == 0
+}
@@ -0,0 +1,45 @@
+/*
+Copyright © 2024 vavuthu@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Report is a report requested with --report
+type Report struct {
+	Format string
+	// Path is where the report is written, empty for the default path of
+	// the format
+	Path string
+}
+
+// ParseReports parses FORMAT[=PATH] values
+func ParseReports(values []string) ([]Report, error) {
+	var reports []Report
+	for _, value := range values {
+		format, path, _ := strings.Cut(value, "=")
+		format = strings.TrimSpace(format)
+		if format == "" {
+			return nil, fmt.Errorf("invalid report %q, expected FORMAT[=PATH]", value)
+		}
+		reports = append(reports, Report{Format: format, Path: strings.TrimSpace(path)})
+	}
+	return reports, nil
+}
@@ -20,7 +20,9 @@ package logger
 import (
 	"fmt"
 	"os"
-	"time"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"	
@@ -28,45 +30,136 @@ import (
 
 var Logger *zap.Logger
 
-func init()  {
-	// construct filename with timestamp
-	currentTime := time.Now()
-	timestamp := currentTime.Format("2019-02-09_17-50-01")
-	filename := "itr_" + timestamp + ".log"
+const secretMask = "*****"
+
+var (
+	secrets     []string
+	secretsLock sync.RWMutex
+)
+
+var (
+	encoderConfig zapcore.EncoderConfig
+	consoleCore zapcore.Core
+	defaultLogLevel = zapcore.DebugLevel
+	consoleDisabled atomic.Bool
+)
 
+func init()  {
 	// create encoderconfig with timeformat
-	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig = zap.NewProductionEncoderConfig()
 	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 	encoderConfig.StacktraceKey = ""
 
-	// create console and file enconder
+	// create console enconder and writer, the log file is added with
+	// SetLogFile once the run directory is known
 	consoleEncoder := zapcore.NewConsoleEncoder(encoderConfig)
-	fileEncoder := zapcore.NewJSONEncoder(encoderConfig)
-	
+	consoleWriter := zapcore.AddSync(os.Stdout)
+	consoleLevel := zap.LevelEnablerFunc(func(level zapcore.Level) bool {
+		return !consoleDisabled.Load() && defaultLogLevel.Enabled(level)
+	})
+	consoleCore = zapcore.NewCore(consoleEncoder, consoleWriter, consoleLevel)
+
+	// create logger
+	Logger = newLogger(consoleCore)
+}
+
+func newLogger(core zapcore.Core) *zap.Logger {
+	return zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel)).WithOptions(zap.AddCallerSkip(1))
+}
+
+// SetLogFile writes the log to the given file in addition to the console
+func SetLogFile(filename string) error {
 	// create log file
 	logFile, err := os.Create(filename)
 	if err != nil {
-		fmt.Errorf("failed to open log file: %v", err)
+		return fmt.Errorf("failed to open log file: %v", err)
 	}
 
-	// create writers for console and file
-	consoleWriter := zapcore.AddSync(os.Stdout)
+	// create file encoder, writer and core
+	fileEncoder := zapcore.NewJSONEncoder(encoderConfig)
 	fileWriter := zapcore.AddSync(logFile)
-	
-	defaultLogLevel := zapcore.DebugLevel
-
-	// create cores for writing to console and file
-	consoleCore := zapcore.NewCore(consoleEncoder, consoleWriter, defaultLogLevel)
 	fileCore := zapcore.NewCore(fileEncoder, fileWriter, defaultLogLevel)
 
 	// create core with both console and file
-	core := zapcore.NewTee(consoleCore, fileCore)
+	Logger = newLogger(zapcore.NewTee(consoleCore, fileCore))
 
-	// create logger
-	Logger = zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel)).WithOptions(zap.AddCallerSkip(1))
-	
 	Logger.Info("log file: " + filename)
+	return nil
+}
+
+// SetConsole turns logging to the console on or off, the log file is
+// written either way
+func SetConsole(enabled bool) {
+	consoleDisabled.Store(!enabled)
+}
+
+// AddSecret registers a value which is masked in every log message
+func AddSecret(value string) {
+	// very short values would mask unrelated text
+	if len(value) < 4 {
+		return
+	}
+	secretsLock.Lock()
+	defer secretsLock.Unlock()
+	secrets = append(secrets, value)
+}
+
+// IsMasked reports whether msg had secret values masked
+func IsMasked(msg string) bool {
+	return strings.Contains(msg, secretMask)
+}
+
+// Mask replaces the registered secret values in msg
+func Mask(msg string) string {
+	secretsLock.RLock()
+	defer secretsLock.RUnlock()
+	for _, secret := range secrets {
+		msg = strings.ReplaceAll(msg, secret, secretMask)
+	}
+	return msg
+}
+
+// SecretLookback is the number of bytes to read before a window of a log so
+// that a secret crossing the start of the window can be recognised
+func SecretLookback() int {
+	secretsLock.RLock()
+	defer secretsLock.RUnlock()
+	lookback := 0
+	for _, secret := range secrets {
+		if len(secret)-1 > lookback {
+			lookback = len(secret) - 1
+		}
+	}
+	return lookback
+}
+
+// MaskFrom masks the registered secret values in msg and returns the masked
+// text from byte start on, a secret crossing start is masked as a whole
+func MaskFrom(msg string, start int) string {
+	secretsLock.RLock()
+	defer secretsLock.RUnlock()
+	var masked strings.Builder
+	for i := 0; i < len(msg); {
+		secret := ""
+		for _, s := range secrets {
+			if len(s) > len(secret) && strings.HasPrefix(msg[i:], s) {
+				secret = s
+			}
+		}
+		if secret == "" {
+			if i >= start {
+				masked.WriteByte(msg[i])
+			}
+			i++
+			continue
+		}
+		if i+len(secret) > start {
+			masked.WriteString(secretMask)
+		}
+		i += len(secret)
+	}
+	return masked.String()
 }
 
 // concatenateMsg concatenates message and values
@@ -84,44 +177,44 @@ func concatenateMsg(msg string, values ...interface{}) string {
 
 // Info logs a message at Info level with optional variable value
 func Info(msg string, values ...interface{}) {
-    Logger.Info(concatenateMsg(msg, values...))
+    Logger.Info(Mask(concatenateMsg(msg, values...)))
 }
 
 // Infof logs a formatted message at Info level
 func Infof(format string, values ...interface{}) {
     msg := fmt.Sprintf(format, values...)
-    Logger.Info(msg)
+    Logger.Info(Mask(msg))
 }
 
 // Warn logs a message at Warn level with optional variable value
 func Warn(msg string, values ...interface{}) {
-    Logger.Warn(concatenateMsg(msg, values...))
+    Logger.Warn(Mask(concatenateMsg(msg, values...)))
 }
 
 // Warnf logs a formatted message at Warn level
 func Warnf(format string, values ...interface{}) {
     msg := fmt.Sprintf(format, values...)
-    Logger.Warn(msg)
+    Logger.Warn(Mask(msg))
 }
 
 // Error logs a message at Error level with optional variable value
 func Error(msg string, values ...interface{}) {
-    Logger.Error(concatenateMsg(msg, values...))
+    Logger.Error(Mask(concatenateMsg(msg, values...)))
 }
 
 // Errorf logs a formatted message at Error level
 func Errorf(format string, values ...interface{}) {
     msg := fmt.Sprintf(format, values...)
-    Logger.Error(msg)
+    Logger.Error(Mask(msg))
 }
 
 // Debug logs a message at Debug level with optional variable value
 func Debug(msg string, values ...interface{}) {
-    Logger.Debug(concatenateMsg(msg, values...))
+    Logger.Debug(Mask(concatenateMsg(msg, values...)))
 }
 
 // Debugf logs a formatted message at Debug level
 func Debugf(format string, values ...interface{}) {
     msg := fmt.Sprintf(format, values...)
-    Logger.Debug(msg)
+    Logger.Debug(Mask(msg))
 }